@@ -1,74 +1,457 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/jolovicdev/nora/internal/app"
+	"github.com/jolovicdev/nora/internal/core/config"
+	"github.com/jolovicdev/nora/internal/core/diff"
+	"github.com/jolovicdev/nora/internal/utils"
 )
 
+// commands are the built-in commands, which aliases cannot replace.
+var commands = map[string]bool{
+	"init": true, "prepare": true, "forget": true, "remove": true, "capture": true,
+	"recall": true, "restore": true, "diff": true, "history": true, "pack": true,
+	"gc": true, "check-ignore": true, "config": true, "fsck": true, "migrate": true,
+	"timeline": true, "status": true,
+}
 
 func main() {
-    if len(os.Args) < 2 {
-        fmt.Println("Usage: nora <command> [arguments]")
-        fmt.Println("Commands:")
-        fmt.Println("  init                  - Initialize a new story")
-        fmt.Println("  prepare <files...>    - Prepare files for snapshot")
-        fmt.Println("  capture <message>     - Create a new snapshot")
-        fmt.Println("  recall <snapshot-id>  - View snapshot details")
-        fmt.Println("  diff <file>          - Show changes in prepared file")
-        os.Exit(1)
-    }
-
-    app := app.New(".nora")
-    var err error
-
-    switch os.Args[1] {
-    case "init":
-        err = app.Initialize()
-    case "prepare":
-        if len(os.Args) < 3 {
-            fmt.Println("Usage: nora prepare <files...>")
-            os.Exit(1)
-        }
-        err = app.PrepareFiles(os.Args[2:])
-    case "forget":
-        if len(os.Args) < 3 {
-            fmt.Println("Usage: nora forget <files...>")
-            os.Exit(1)
-        }
-        err = app.Forget(os.Args[2:])
-    case "capture":
-        if len(os.Args) < 3 {
-            fmt.Println("Usage: nora capture <message>")
-            os.Exit(1)
-        }
-        err = app.CreateSnapshot(os.Args[2])
-    case "recall":
-        if len(os.Args) < 3 {
-            fmt.Println("Usage: nora recall <snapshot-id>")
-            os.Exit(1)
-        }
-        err = app.RecallSnapshot(os.Args[2])
-    case "diff":
-        if len(os.Args) < 3 {
-            fmt.Println("Usage: nora diff <file>")
-            os.Exit(1)
-        }
-        err = app.ShowDiff(os.Args[2])
-    case "status":
-        err = app.GetStatus()
-        if err != nil {
-            fmt.Printf("Error getting status: %v\n", err)
-            os.Exit(1)
-        }
-    default:
-        fmt.Printf("Unknown command: %s\n", os.Args[1])
-        os.Exit(1)
-    }
-
-    if err != nil {
-        fmt.Printf("Error: %v\n", err)
-        os.Exit(1)
-    }
-}
\ No newline at end of file
+	args, storyDir := globalFlags(os.Args[1:])
+	if len(args) < 1 {
+		fmt.Println("Usage: nora [--story-dir <dir>] <command> [arguments]")
+		fmt.Println("Commands:")
+		fmt.Println("  init                  - Initialize a new story")
+		fmt.Println("  prepare <files...>    - Prepare files for snapshot")
+		fmt.Println("  remove <files...>     - Prepare the deletion of tracked files")
+		fmt.Println("  capture <message>     - Create a new snapshot (--author \"Name <email>\", --date)")
+		fmt.Println("  recall <snapshot-id>  - View snapshot details")
+		fmt.Println("  restore <snapshot-id> [paths...] - Restore files from a snapshot")
+		fmt.Println("  diff [revs] [paths]   - Show changes between the working tree, prepared files and snapshots")
+		fmt.Println("  history [paths...]    - Show snapshots on the current timeline")
+		fmt.Println("  timeline [command]    - List, create, switch, rename or delete timelines")
+		fmt.Println("  pack [--all]          - Bundle loose objects into a delta-compressed pack")
+		fmt.Println("  gc [--dry-run]        - Remove snapshots and objects no timeline or prepared file leads to")
+		fmt.Println("  check-ignore <paths...> - Show which .noraignore rule matches each path")
+		fmt.Println("  config <command>      - Get, set, unset or list settings")
+		fmt.Println("  fsck                  - Verify objects, snapshots and timelines")
+		fmt.Println("  migrate               - Upgrade the story to the current storage format")
+		fmt.Println()
+		fmt.Println("The story is found by searching the current directory and its parents for")
+		fmt.Println(".nora; --story-dir or NORA_DIR name it explicitly. Aliases set with")
+		fmt.Println("'nora config set alias.<name> <command>' work like commands.")
+		os.Exit(1)
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if storyDir == "" {
+		storyDir = os.Getenv("NORA_DIR")
+	}
+	loc, err := app.Locate(cwd, storyDir, args[0] == "init")
+	switch {
+	case err == nil:
+		storyDir, err = loc.Enter()
+	case args[0] == "config" && storyDir == "":
+		// The user and system settings need no story.
+		err = nil
+	}
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	app := app.New(storyDir)
+
+	if args[0] != "init" && storyDir != "" {
+		if err := app.Open(); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if !commands[args[0]] {
+			if args, err = app.ExpandAlias(args); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+	}
+
+	stopOutput, err := app.SetupOutput(args[0] == "diff" || args[0] == "history")
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "init":
+		err = app.Initialize()
+	case "prepare":
+		fs := flag.NewFlagSet("prepare", flag.ExitOnError)
+		jobs := fs.Int("jobs", 0, "number of files to hash in parallel (default: number of CPUs)")
+		paths := parseFlags(fs, args[1:])
+		if len(paths) == 0 {
+			fmt.Println("Usage: nora prepare [--jobs N] <files...>")
+			os.Exit(1)
+		}
+		app.SetJobs(*jobs)
+		err = app.PrepareFiles(repoPaths(loc, paths))
+	case "forget":
+		if len(args) < 2 {
+			fmt.Println("Usage: nora forget <files...>")
+			os.Exit(1)
+		}
+		err = app.Forget(repoPaths(loc, args[1:]))
+	case "remove":
+		fs := flag.NewFlagSet("remove", flag.ExitOnError)
+		keep := fs.Bool("keep", false, "keep the files in the working directory")
+		force := fs.Bool("force", false, "delete files with unprepared changes")
+		args := parseFlags(fs, args[1:])
+		if len(args) < 1 {
+			fmt.Println("Usage: nora remove [--keep | --force] <files...>")
+			os.Exit(1)
+		}
+		err = app.RemoveFiles(repoPaths(loc, args), *keep, *force)
+	case "capture":
+		err = runCapture(app, args[1:])
+	case "recall":
+		if len(args) < 2 {
+			fmt.Println("Usage: nora recall <snapshot-id>")
+			os.Exit(1)
+		}
+		err = app.RecallSnapshot(args[1])
+	case "restore":
+		fs := flag.NewFlagSet("restore", flag.ExitOnError)
+		force := fs.Bool("force", false, "overwrite files with unprepared changes")
+		args := parseFlags(fs, args[1:])
+		if len(args) < 1 {
+			fmt.Println("Usage: nora restore [--force] <snapshot-id> [paths...]")
+			os.Exit(1)
+		}
+		err = app.RestoreSnapshot(args[0], repoPaths(loc, args[1:]), *force)
+	case "diff":
+		err = runDiff(app, loc, args[1:])
+	case "history":
+		err = runHistory(app, loc, args[1:])
+	case "pack":
+		fs := flag.NewFlagSet("pack", flag.ExitOnError)
+		all := fs.Bool("all", false, "repack existing packs together with loose objects")
+		parseFlags(fs, args[1:])
+		err = app.Pack(*all)
+	case "gc":
+		err = runGC(app, args[1:])
+	case "check-ignore":
+		if len(args) < 2 {
+			fmt.Println("Usage: nora check-ignore <paths...>")
+			os.Exit(1)
+		}
+		var ignored bool
+		ignored, err = app.CheckIgnore(repoPaths(loc, args[1:]))
+		if err == nil && !ignored {
+			os.Exit(1)
+		}
+	case "config":
+		err = runConfig(app, args[1:])
+	case "fsck":
+		err = runFsck(app)
+	case "migrate":
+		err = app.Migrate()
+	case "timeline":
+		err = runTimeline(app, args[1:])
+	case "status":
+		fs := flag.NewFlagSet("status", flag.ExitOnError)
+		jobs := fs.Int("jobs", 0, "number of files to hash in parallel (default: number of CPUs)")
+		parseFlags(fs, args[1:])
+		app.SetJobs(*jobs)
+		err = app.GetStatus()
+		if err != nil {
+			fmt.Printf("Error getting status: %v\n", err)
+			os.Exit(1)
+		}
+	default:
+		fmt.Printf("Unknown command: %s\n", args[0])
+		os.Exit(1)
+	}
+
+	stopOutput()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// runFsck exits with the fsck problem bitmask so scripts can tell the
+// kinds of damage apart.
+func runFsck(a *app.App) error {
+	err := a.Fsck()
+	var fsckErr *app.FsckError
+	if errors.As(err, &fsckErr) {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(fsckErr.Code)
+	}
+	return err
+}
+
+// globalFlags takes --story-dir off the front of the command line.
+func globalFlags(args []string) ([]string, string) {
+	storyDir := ""
+	for len(args) > 0 {
+		switch {
+		case args[0] == "--story-dir" && len(args) > 1:
+			storyDir = args[1]
+			args = args[2:]
+		case strings.HasPrefix(args[0], "--story-dir="):
+			storyDir = strings.TrimPrefix(args[0], "--story-dir=")
+			args = args[1:]
+		default:
+			return args, storyDir
+		}
+	}
+	return args, storyDir
+}
+
+// repoPaths maps command line paths, given relative to where nora was
+// started, to working tree paths.
+func repoPaths(loc *app.Location, paths []string) []string {
+	mapped, err := loc.RepoPaths(paths)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	return mapped
+}
+
+func parseFlags(fs *flag.FlagSet, args []string) []string {
+	var rest []string
+	for {
+		fs.Parse(args)
+		consumed := len(args) - fs.NArg()
+		if consumed > 0 && args[consumed-1] == "--" {
+			return append(rest, fs.Args()...)
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return rest
+		}
+		rest = append(rest, args[0])
+		args = args[1:]
+	}
+}
+
+func runTimeline(a *app.App, args []string) error {
+	if len(args) == 0 {
+		return a.ListTimelines()
+	}
+
+	fs := flag.NewFlagSet("timeline "+args[0], flag.ExitOnError)
+	force := fs.Bool("force", false, "discard changes or delete unmerged snapshots")
+	switchTo := fs.Bool("switch", false, "switch to the new timeline")
+	rest := parseFlags(fs, args[1:])
+
+	switch args[0] {
+	case "list":
+		return a.ListTimelines()
+	case "create":
+		if len(rest) != 1 {
+			fmt.Println("Usage: nora timeline create [--switch] <name>")
+			os.Exit(1)
+		}
+		return a.CreateTimeline(rest[0], *switchTo)
+	case "switch":
+		if len(rest) != 1 {
+			fmt.Println("Usage: nora timeline switch [--force] <name>")
+			os.Exit(1)
+		}
+		return a.SwitchTimeline(rest[0], *force)
+	case "delete":
+		if len(rest) != 1 {
+			fmt.Println("Usage: nora timeline delete [--force] <name>")
+			os.Exit(1)
+		}
+		return a.DeleteTimeline(rest[0], *force)
+	case "rename":
+		if len(rest) != 2 {
+			fmt.Println("Usage: nora timeline rename <old> <new>")
+			os.Exit(1)
+		}
+		return a.RenameTimeline(rest[0], rest[1])
+	default:
+		return fmt.Errorf("unknown timeline command: %s", args[0])
+	}
+}
+
+func runConfig(a *app.App, args []string) error {
+	if len(args) == 0 {
+		fmt.Println("Usage: nora config get [--user | --system] <key>")
+		fmt.Println("       nora config set [--user | --system] <key> <value>")
+		fmt.Println("       nora config unset [--user | --system] <key>")
+		fmt.Println("       nora config list [--show-origin]")
+		fmt.Println("       nora config [--user | --system] <key> [value]")
+		fmt.Println()
+		fmt.Println("Settings are read from /etc/nora/config, ~/.config/nora/config, .nora/config")
+		fmt.Println("and the environment, later ones winning. Outside a story only the user and")
+		fmt.Println("system files are used. Keys:")
+		for _, key := range config.Keys {
+			fmt.Printf("  %-16s %s (%s)\n", key.Name, key.Help, key.Env)
+		}
+		fmt.Printf("  %-16s %s\n", "alias.<name>", "command run by 'nora <name>'")
+		os.Exit(1)
+	}
+
+	// Flags may come before or after the command, as in
+	// "nora config --user user.name".
+	fs := flag.NewFlagSet("config", flag.ExitOnError)
+	user := fs.Bool("user", false, "use the user file, ~/.config/nora/config")
+	system := fs.Bool("system", false, "use the system file, /etc/nora/config")
+	showOrigin := fs.Bool("show-origin", false, "show the file or variable each value comes from")
+	rest := parseFlags(fs, args)
+	if len(rest) == 0 {
+		return runConfig(a, nil)
+	}
+	scope := config.Repo
+	if *user {
+		scope = config.User
+	}
+	if *system {
+		scope = config.System
+	}
+
+	// "nora config <key> [value]" is short for get and set.
+	command, rest := rest[0], rest[1:]
+	if strings.Contains(command, ".") {
+		rest = append([]string{command}, rest...)
+		command = "get"
+		if len(rest) == 2 {
+			command = "set"
+		}
+	}
+
+	switch {
+	case command == "get" && len(rest) == 1:
+		get := a.GetConfig
+		if *user || *system {
+			get = func(key string) (bool, error) { return a.GetScopedConfig(scope, key) }
+		}
+		set, err := get(rest[0])
+		if err == nil && !set {
+			os.Exit(1)
+		}
+		return err
+	case command == "set" && len(rest) == 2:
+		return a.SetConfig(scope, rest[0], rest[1])
+	case command == "unset" && len(rest) == 1:
+		return a.UnsetConfig(scope, rest[0])
+	case command == "list" && len(rest) == 0:
+		return a.ListConfig(*showOrigin)
+	case command == "get" || command == "set" || command == "unset" || command == "list":
+		return runConfig(a, nil)
+	default:
+		return fmt.Errorf("unknown config command: %s", command)
+	}
+}
+
+func runCapture(a *app.App, args []string) error {
+	fs := flag.NewFlagSet("capture", flag.ExitOnError)
+	author := fs.String("author", "", "record a different author, as \"Name <email>\"")
+	date := fs.String("date", "", "record a different author date")
+	rest := parseFlags(fs, args)
+	if len(rest) != 1 {
+		fmt.Println("Usage: nora capture [--author \"Name <email>\"] [--date date] <message>")
+		os.Exit(1)
+	}
+
+	opts := app.CaptureOptions{Author: *author}
+	if *date != "" {
+		var err error
+		if opts.Date, err = utils.ParseDate(*date, time.Now()); err != nil {
+			return err
+		}
+	}
+	return a.CreateSnapshot(rest[0], opts)
+}
+
+func runHistory(a *app.App, loc *app.Location, args []string) error {
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	since := fs.String("since", "", "show snapshots newer than a date (e.g. 2024-01-31, \"2 weeks ago\")")
+	until := fs.String("until", "", "show snapshots older than a date")
+	grep := fs.String("grep", "", "show snapshots whose message matches a regular expression")
+	limit := fs.Int("limit", 0, "show at most n snapshots")
+	oneLine := fs.Bool("oneline", false, "show one snapshot per line")
+	var paths []string
+	fs.Func("path", "show snapshots that changed a path (repeatable)", func(value string) error {
+		paths = append(paths, value)
+		return nil
+	})
+	paths = append(paths, parseFlags(fs, args)...)
+
+	opts := app.HistoryOptions{
+		Paths:   repoPaths(loc, paths),
+		Grep:    *grep,
+		Limit:   *limit,
+		OneLine: *oneLine,
+	}
+	var err error
+	if *since != "" {
+		if opts.Since, err = utils.ParseDate(*since, time.Now()); err != nil {
+			return err
+		}
+	}
+	if *until != "" {
+		if opts.Until, err = utils.ParseDate(*until, time.Now()); err != nil {
+			return err
+		}
+	}
+	return a.ShowHistory(opts)
+}
+
+func runDiff(a *app.App, loc *app.Location, args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	context := fs.Int("U", diff.DefaultContext, "number of context lines")
+	prepared := fs.Bool("prepared", false, "compare prepared files against a snapshot (default head)")
+	algorithm := fs.String("algorithm", "", "diff algorithm: "+strings.Join(diff.Algorithms(), ", "))
+	wordDiff := fs.Bool("word-diff", false, "show changed words inline as [-old-]{+new+}")
+
+	var paths []string
+	for i, arg := range args {
+		if arg == "--" {
+			paths = args[i+1:]
+			args = args[:i]
+			break
+		}
+	}
+	rest := parseFlags(fs, args)
+
+	opts := app.DiffOptions{
+		Prepared:  *prepared,
+		Context:   *context,
+		Algorithm: *algorithm,
+		WordDiff:  *wordDiff,
+	}
+	if paths != nil {
+		opts.Revisions = rest
+		opts.Paths = repoPaths(loc, paths)
+	} else {
+		var err error
+		if opts.Revisions, opts.Paths, err = a.SplitRevisions(rest, loc.RepoPath); err != nil {
+			return err
+		}
+	}
+	return a.ShowDiff(opts)
+}
+
+func runGC(a *app.App, args []string) error {
+	fs := flag.NewFlagSet("gc", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "only list the objects that would be removed")
+	grace := fs.Duration("grace", app.DefaultGCGrace, "keep unreachable objects younger than this")
+	pack := fs.Bool("pack", false, "pack the remaining objects afterwards")
+	parseFlags(fs, args)
+	return a.GarbageCollect(app.GCOptions{DryRun: *dryRun, Grace: *grace, Pack: *pack})
+}
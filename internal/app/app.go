@@ -3,10 +3,12 @@ package app
 import (
 	"fmt"
 	"os"
-	"path/filepath"
 	"strings"
 
+	"github.com/jolovicdev/nora/internal/core/config"
 	"github.com/jolovicdev/nora/internal/core/diff"
+	"github.com/jolovicdev/nora/internal/core/layout"
+	"github.com/jolovicdev/nora/internal/core/lock"
 	"github.com/jolovicdev/nora/internal/core/snapshot"
 	"github.com/jolovicdev/nora/internal/core/storage"
 	"github.com/jolovicdev/nora/internal/core/timeline"
@@ -14,523 +16,491 @@ import (
 	"github.com/jolovicdev/nora/internal/utils"
 )
 
-const(
-	Reset = "\033[0m" 
-	Red = "\033[31m" 
-	Green = "\033[32m" 
-	Yellow = "\033[33m" 
-	Blue = "\033[34m" 
-	Magenta = "\033[35m" 
-	Cyan = "\033[36m" 
-	Gray = "\033[37m" 
-	White = "\033[97m"
+// Colours are variables so SetupOutput can blank them when colour is off.
+var (
+	Reset   = "\033[0m"
+	Red     = "\033[31m"
+	Green   = "\033[32m"
+	Yellow  = "\033[33m"
+	Blue    = "\033[34m"
+	Magenta = "\033[35m"
+	Cyan    = "\033[36m"
+	Gray    = "\033[37m"
+	White   = "\033[97m"
 )
 
-
 type App struct {
-    contentStore *storage.ContentStore
-    index       *storage.Index
-    snapshots   *snapshot.Store
-    timelines   *timeline.Manager
+	layout       *layout.Layout
+	contentStore *storage.ContentStore
+	index        *storage.Index
+	snapshots    *snapshot.Store
+	timelines    *timeline.Manager
+	jobs         int
+	storyLock    *lock.Lock
+	lockDepth    int
+	settings     *config.Config
+	color        bool
 }
+
 func (app *App) PrepareFiles(paths []string) error {
-    prepared := make(map[string]string)
-    
-    existing, err := app.index.GetPreparedFiles()
-    if err == nil {
-        for k, v := range existing {
-            prepared[k] = v
-        }
-    }
-    ignorePatterns, err := app.loadIgnorePatterns()
-    if err != nil {
-        return fmt.Errorf("failed to load ignore patterns: %w", err)
-    }
-
-    for _, path := range paths {
-        if path == "." {
-            err := filepath.Walk(".", func(path string, info os.FileInfo, err error) error {
-                if err != nil {
-                    return err
-                }
-
-
-                if strings.HasPrefix(path, ".nora") {
-                    return filepath.SkipDir
-                }
-
-
-                if shouldIgnore(path, ignorePatterns) {
-                    if info.IsDir() {
-                        return filepath.SkipDir
-                    }
-                    return nil
-                }
-
-                if !info.IsDir() {
-                    status, err := app.getFileStatus(path, info)
-                    if err != nil {
-                        return fmt.Errorf("failed to get status for %s: %w", path, err)
-                    }
-
-                    if status.State != "unchanged" {
-                        if err := app.prepareFile(path, prepared); err != nil {
-                            return fmt.Errorf("failed to prepare %s: %w", path, err)
-                        }
-                    }
-                }
-                return nil
-            })
-            if err != nil {
-                return fmt.Errorf("failed to walk directory: %w", err)
-            }
-        } else {
-
-            _, err := os.Lstat(path)
-            if err != nil {
-                return fmt.Errorf("failed to stat %s: %w", path, err)
-            }
-
-            if shouldIgnore(path, ignorePatterns) {
-                continue
-            }
-
-            if err := app.prepareFile(path, prepared); err != nil {
-                return fmt.Errorf("failed to prepare %s: %w", path, err)
-            }
-        }
-    }
-
-    return app.index.PrepareFiles(prepared)
-}
+	unlock, err := app.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	prepared := make(map[string]string)
 
-func (app *App) prepareFile(path string, prepared map[string]string) error {
-    info, err := os.Lstat(path)
-    if err != nil {
-        return fmt.Errorf("failed to stat %s: %w", path, err)
-    }
-
-    var content []byte
-    if info.Mode()&os.ModeSymlink != 0 {
-
-        target, err := os.Readlink(path)
-        if err != nil {
-            return fmt.Errorf("failed to read symlink %s: %w", path, err)
-        }
-        content = []byte(target)
-    } else {
-
-        content, err = os.ReadFile(path)
-        if err != nil {
-            return fmt.Errorf("failed to read file %s: %w", path, err)
-        }
-    }
-    hash, err := app.contentStore.Store(content)
-    if err != nil {
-        return fmt.Errorf("failed to store content for %s: %w", path, err)
-    }
-
-    prepared[path] = hash
-    fmt.Printf("Prepared: %s\n", path)
-    return nil
+	existing, err := app.index.GetPreparedFiles()
+	if err == nil {
+		for k, v := range existing {
+			prepared[k] = v
+		}
+	}
+	modes, err := app.index.GetPreparedModes()
+	if err != nil {
+		return fmt.Errorf("failed to get prepared modes: %w", err)
+	}
+	matcher, err := app.ignoreMatcher()
+	if err != nil {
+		return fmt.Errorf("failed to load ignore patterns: %w", err)
+	}
+	head, err := app.headSnapshot()
+	if err != nil {
+		return err
+	}
+	skip := walkFilter(app.layout.Root(), matcher, head.Files, prepared)
+	cache, err := app.index.GetStatCache()
+	if err != nil {
+		return fmt.Errorf("failed to load stat cache: %w", err)
+	}
+
+	for _, path := range paths {
+		info, err := os.Lstat(path)
+		if os.IsNotExist(err) {
+			tracked := trackedUnder(head.Files, path)
+			if len(tracked) == 0 {
+				return fmt.Errorf("failed to stat %s: %w", path, err)
+			}
+			for _, t := range tracked {
+				prepareDeletion(t, head, prepared, modes)
+			}
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to stat %s: %w", path, err)
+		}
+
+		if path != "." {
+			if ignored, err := skip(path, info.IsDir()); err != nil {
+				return err
+			} else if ignored {
+				continue
+			}
+		}
+
+		if info.IsDir() {
+			if err := app.prepareDir(path, head, prepared, modes, skip, cache); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := app.prepareFile(path, prepared, modes, cache); err != nil {
+			return fmt.Errorf("failed to prepare %s: %w", path, err)
+		}
+	}
+
+	if err := app.index.SaveStatCache(cache); err != nil {
+		return fmt.Errorf("failed to save stat cache: %w", err)
+	}
+	if err := app.index.PrepareModes(modes); err != nil {
+		return fmt.Errorf("failed to save prepared modes: %w", err)
+	}
+	return app.index.PrepareFiles(prepared)
 }
-func (app *App) GetStatus() error {
 
-    timeline, err := app.timelines.GetCurrent()
-    if err != nil {
-        return fmt.Errorf("failed to get current timeline: %w", err)
-    }
+// prepareDir prepares every file under dir that differs from what is
+// already prepared or captured, then prepares the deletion of tracked files
+// under dir that no longer exist.
+func (app *App) prepareDir(dir string, head *types.Snapshot, prepared map[string]string, modes map[string]os.FileMode, skip func(string, bool) (bool, error), cache storage.StatCache) error {
+	// Workers compare against prepared and modes while they hash, so
+	// changes are only applied once the walk is over.
+	var changed []*workingFile
+	err := app.walkWorkingTree(dir, skip, cache, func(file *workingFile) error {
+		want, isPrepared := prepared[file.path]
+		wantMode, hasMode := modes[file.path]
+		if !isPrepared {
+			want = head.Files[file.path]
+			wantMode, hasMode = head.Modes[file.path]
+		}
+		if file.hash == want && (!hasMode || wantMode == file.mode) {
+			return nil
+		}
+
+		content, err := file.load()
+		if err != nil {
+			return err
+		}
+		if file.hash, err = app.contentStore.Store(content); err != nil {
+			return fmt.Errorf("failed to store content: %w", err)
+		}
+		file.state = "prepared"
+		return nil
+	}, func(file *workingFile) error {
+		if file.state != "" {
+			changed = append(changed, file)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to walk directory: %w", err)
+	}
 
+	for _, file := range changed {
+		prepared[file.path] = file.hash
+		modes[file.path] = file.mode
+		fmt.Printf("Prepared: %s\n", file.path)
+	}
 
-    prepared, err := app.index.GetPreparedFiles()
-    if err != nil {
-        prepared = make(map[string]string)
-    }
-
+	candidates := make(map[string]string)
+	for p, hash := range head.Files {
+		candidates[p] = hash
+	}
+	for p, hash := range prepared {
+		if hash != "" {
+			candidates[p] = hash
+		}
+	}
+	for _, tracked := range trackedUnder(candidates, dir) {
+		if _, err := os.Lstat(tracked); os.IsNotExist(err) {
+			prepareDeletion(tracked, head, prepared, modes)
+			delete(cache, tracked)
+		}
+	}
+	return nil
+}
 
-    var snapshotFiles map[string]string
-    if timeline.Current != "" {
-        snapshot, err := app.snapshots.Get(timeline.Current)
-        if err != nil {
-            return fmt.Errorf("failed to get current snapshot: %w", err)
-        }
-        snapshotFiles = snapshot.Files
-    } else {
-        snapshotFiles = make(map[string]string)
-    }
-
-
-    changes := make(map[string]types.FileChange)
-
-
-    err = filepath.Walk(".", func(path string, info os.FileInfo, err error) error {
-        if err != nil {
-            return err
-        }
-
-
-        if strings.HasPrefix(path, ".nora") || shouldIgnore(path, []string{}) {
-            if info.IsDir() {
-                return filepath.SkipDir
-            }
-            return nil
-        }
-
-
-        if info.IsDir() {
-            return nil
-        }
-
-
-        content, err := os.ReadFile(path)
-        if err != nil {
-            return fmt.Errorf("failed to read file %s: %w", path, err)
-        }
-
-        currentHash, err := app.contentStore.Store(content)
-        if err != nil {
-            return fmt.Errorf("failed to hash content for %s: %w", path, err)
-        }
-
-
-        preparedHash, isPrepared := prepared[path]
-        
-
-        snapshotHash, inSnapshot := snapshotFiles[path]
-
-        var state string
-        switch {
-        case isPrepared:
-            if inSnapshot {
-                if preparedHash != snapshotHash {
-                    state = "modified (prepared)"
-                } else {
-                    state = "unchanged"
-                }
-            } else {
-                state = "added (prepared)"
-            }
-        case inSnapshot:
-            if currentHash != snapshotHash {
-                state = "modified"
-            } else {
-                state = "unchanged"
-            }
-        default:
-            state = "untracked"
-        }
-
-        if state != "unchanged" {
-            changes[path] = types.FileChange{Path: path, State: state}
-        }
-
-        return nil
-    })
-
-    if err != nil {
-        return fmt.Errorf("failed to walk directory: %w", err)
-    }
-
-
-    fmt.Printf("\nOn timeline: %s\n", timeline.Name)
-    
-
-    hasPrepared := false
-    fmt.Println("\nChanges prepared for snapshot:")
-    for path, change := range changes {
-        if strings.Contains(change.State, "prepared") {
-            hasPrepared = true
-            fmt.Printf("%s%s: %s%s\n", Green, path, change.State, Reset)
-        }
-    }
-    if !hasPrepared {
-        fmt.Println("  no changes prepared")
-    }
-
-
-    hasUnprepared := false
-    fmt.Println("\nChanges not prepared for snapshot:")
-    for path, change := range changes {
-        if !strings.Contains(change.State, "prepared") && change.State != "unchanged" {
-            hasUnprepared = true
-            switch change.State {
-            case "modified":
-                fmt.Printf("%s%s: %s%s\n", Red, path, change.State, Reset)
-            case "untracked":
-                fmt.Printf("%s%s: %s%s\n", Blue, path, change.State, Reset)
-            }
-        }
-    }
-    if !hasUnprepared {
-        fmt.Println("  working directory clean")
-    }
-
-    fmt.Println()
-    return nil
+func (app *App) prepareFile(path string, prepared map[string]string, modes map[string]os.FileMode, cache storage.StatCache) error {
+	content, mode, err := readWorkingFile(path)
+	if err != nil {
+		return err
+	}
+	hash, err := app.contentStore.Store(content)
+	if err != nil {
+		return fmt.Errorf("failed to store content for %s: %w", path, err)
+	}
+	if info, err := os.Lstat(path); err == nil {
+		cache.Record(path, info, hash)
+	}
+
+	prepared[path] = hash
+	modes[path] = mode
+	fmt.Printf("Prepared: %s\n", path)
+	return nil
 }
 
-func (app *App) getFileStatus(path string, info os.FileInfo) (types.FileStatus, error) {
-    status := types.FileStatus{
-        Path:     path,
-        Mode:     info.Mode(),
-        IsSymlink: info.Mode()&os.ModeSymlink != 0,
-    }
+func prepareDeletion(path string, head *types.Snapshot, prepared map[string]string, modes map[string]os.FileMode) {
+	if _, tracked := head.Files[path]; tracked {
+		prepared[path] = ""
+	} else {
+		delete(prepared, path)
+	}
+	delete(modes, path)
+	fmt.Printf("Prepared deletion: %s\n", path)
+}
 
+func readWorkingFile(path string) ([]byte, os.FileMode, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to stat %s: %w", path, err)
+	}
 
-    timeline, err := app.timelines.GetCurrent()
-    if err != nil {
-        return status, fmt.Errorf("failed to get current timeline: %w", err)
-    }
+	if info.Mode()&os.ModeSymlink != 0 {
 
+		target, err := os.Readlink(path)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to read symlink %s: %w", path, err)
+		}
+		return []byte(target), os.ModeSymlink, nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read file %s: %w", path, err)
+	}
+	return content, info.Mode().Perm(), nil
+}
 
-    if timeline.Current == "" {
-        status.State = "added"
-        return status, nil
-    }
+func (app *App) GetStatus() error {
 
+	timeline, err := app.timelines.GetCurrent()
+	if err != nil {
+		return fmt.Errorf("failed to get current timeline: %w", err)
+	}
 
-    snapshot, err := app.snapshots.Get(timeline.Current)
-    if err != nil {
-        return status, fmt.Errorf("failed to get current snapshot: %w", err)
-    }
+	prepared, err := app.index.GetPreparedFiles()
+	if err != nil {
+		prepared = make(map[string]string)
+	}
 
+	attrs, err := app.loadAttributes()
+	if err != nil {
+		return err
+	}
+	cache, err := app.index.GetStatCache()
+	if err != nil {
+		return fmt.Errorf("failed to load stat cache: %w", err)
+	}
 
-    oldHash, exists := snapshot.Files[path]
-    if !exists {
-        status.State = "added"
-        return status, nil
-    }
+	var snapshotFiles map[string]string
+	if timeline.Current != "" {
+		snapshot, err := app.snapshots.Get(timeline.Current)
+		if err != nil {
+			return fmt.Errorf("failed to get current snapshot: %w", err)
+		}
+		snapshotFiles = snapshot.Files
+	} else {
+		snapshotFiles = make(map[string]string)
+	}
 
+	changes := make(map[string]types.FileChange)
 
-    content, err := os.ReadFile(path)
-    if err != nil {
-        return status, fmt.Errorf("failed to read file: %w", err)
-    }
+	matcher, err := app.ignoreMatcher()
+	if err != nil {
+		return fmt.Errorf("failed to load ignore patterns: %w", err)
+	}
+	skip := walkFilter(app.layout.Root(), matcher, snapshotFiles, prepared)
+	refreshed := false
+	err = app.walkWorkingTree(".", skip, cache, func(file *workingFile) error {
+		preparedHash, isPrepared := prepared[file.path]
+		snapshotHash, inSnapshot := snapshotFiles[file.path]
+
+		switch {
+		case isPrepared && preparedHash == "":
+			file.state = "deleted (prepared)"
+		case isPrepared:
+			if inSnapshot {
+				if preparedHash != snapshotHash {
+					file.state = "modified (prepared)"
+				} else {
+					file.state = "unchanged"
+				}
+			} else {
+				file.state = "added (prepared)"
+			}
+		case inSnapshot:
+			if file.hash != snapshotHash {
+				file.state = "modified"
+			} else {
+				file.state = "unchanged"
+			}
+		default:
+			file.state = "untracked"
+		}
+
+		if file.state != "unchanged" {
+			content, err := file.load()
+			if err != nil {
+				return err
+			}
+			file.binary = attrs.IsBinary(file.path, content)
+		}
+		return nil
+	}, func(file *workingFile) error {
+		refreshed = refreshed || file.fresh
+		if file.state != "unchanged" {
+			changes[file.path] = types.FileChange{Path: file.path, State: file.state, Binary: file.binary}
+		}
+		return nil
+	})
 
+	if err != nil {
+		return fmt.Errorf("failed to walk directory: %w", err)
+	}
+	if refreshed {
+		app.saveStatCache(cache)
+	}
 
-    newHash, err := app.contentStore.Store(content)
-    if err != nil {
-        return status, fmt.Errorf("failed to hash content: %w", err)
-    }
+	for path, hash := range prepared {
+		if hash == "" {
+			changes[path] = types.FileChange{Path: path, State: "deleted (prepared)"}
+		}
+	}
+	for path := range snapshotFiles {
+		if _, isPrepared := prepared[path]; isPrepared {
+			continue
+		}
+		if _, err := os.Lstat(path); os.IsNotExist(err) {
+			changes[path] = types.FileChange{Path: path, State: "deleted"}
+		}
+	}
 
-    if newHash != oldHash {
-        status.State = "modified"
-    } else {
-        status.State = "unchanged"
-    }
+	deleted := make(map[string]string)
+	added := make(map[string]string)
+	for path, change := range changes {
+		switch change.State {
+		case "deleted (prepared)":
+			deleted[path] = snapshotFiles[path]
+		case "added (prepared)":
+			added[path] = prepared[path]
+		}
+	}
+	for _, rename := range diff.DetectRenames(deleted, added, app.contentStore.Get, diff.DefaultRenameThreshold) {
+		delete(changes, rename.From)
+		changes[rename.To] = types.FileChange{Path: rename.To, From: rename.From, State: "renamed (prepared)"}
+	}
 
-    return status, nil
+	fmt.Printf("\nOn timeline: %s\n", timeline.Name)
+
+	hasPrepared := false
+	fmt.Println("\nChanges prepared for snapshot:")
+	for path, change := range changes {
+		if change.State == "renamed (prepared)" {
+			hasPrepared = true
+			fmt.Printf("%srenamed: %s -> %s%s\n", Green, change.From, path, Reset)
+		} else if strings.Contains(change.State, "prepared") {
+			hasPrepared = true
+			fmt.Printf("%s%s: %s%s%s\n", Green, path, change.State, binaryMarker(change), Reset)
+		}
+	}
+	if !hasPrepared {
+		fmt.Println("  no changes prepared")
+	}
+
+	hasUnprepared := false
+	fmt.Println("\nChanges not prepared for snapshot:")
+	for path, change := range changes {
+		if !strings.Contains(change.State, "prepared") && change.State != "unchanged" {
+			hasUnprepared = true
+			switch change.State {
+			case "modified", "deleted":
+				fmt.Printf("%s%s: %s%s%s\n", Red, path, change.State, binaryMarker(change), Reset)
+			case "untracked":
+				fmt.Printf("%s%s: %s%s%s\n", Blue, path, change.State, binaryMarker(change), Reset)
+			}
+		}
+	}
+	if !hasUnprepared {
+		fmt.Println("  working directory clean")
+	}
+
+	fmt.Println()
+	return nil
 }
 
+// saveStatCache keeps the hashes status worked out so the next run can
+// skip those files. It is best effort: when another command holds the
+// lock, a later run records them instead.
+func (app *App) saveStatCache(cache storage.StatCache) {
+	unlock, err := app.lock()
+	if err != nil {
+		return
+	}
+	defer unlock()
+	app.index.SaveStatCache(cache)
+}
 
-func (app *App) loadIgnorePatterns() ([]string, error) {
-    patterns := []string{
-        ".nora",
-        ".noraignore",
-        ".git",
-        ".svn",
-        "node_modules",
-        "*.tmp",
-        "*.swp",
-	  "nora",
-    }
-
-    data, err := os.ReadFile(".noraignore")
-    if err == nil {
-        lines := strings.Split(string(data), "\n")
-        for _, line := range lines {
-            line = strings.TrimSpace(line)
-            if line != "" && !strings.HasPrefix(line, "#") {
-                patterns = append(patterns, line)
-            }
-        }
-    }
-
-    return patterns, nil
+func binaryMarker(change types.FileChange) string {
+	if change.Binary {
+		return " (binary)"
+	}
+	return ""
 }
 
+func (app *App) CreateSnapshot(message string, opts CaptureOptions) error {
+	unlock, err := app.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
 
-func shouldIgnore(path string, patterns []string) bool {
-    for _, pattern := range patterns {
-        matched, err := filepath.Match(pattern, filepath.Base(path))
-        if err == nil && matched {
-            return true
-        }
+	prepared, err := app.index.GetPreparedFiles()
+	if err != nil {
+		return fmt.Errorf("failed to get prepared files: %v", err)
+	}
 
-        if strings.HasSuffix(pattern, "/") && strings.HasPrefix(path, pattern) {
-            return true
-        }
-    }
-    return false
-}
+	if len(prepared) == 0 {
+		return fmt.Errorf("no files prepared for snapshot")
+	}
 
-func (app *App) CreateSnapshot(message string) error {
-    prepared, err := app.index.GetPreparedFiles()
-    if err != nil {
-        return fmt.Errorf("failed to get prepared files: %v", err)
-    }
-
-    if len(prepared) == 0 {
-        return fmt.Errorf("no files prepared for snapshot")
-    }
-
-    timeline, err := app.timelines.GetCurrent()
-    if err != nil {
-        return fmt.Errorf("failed to get current timeline: %v", err)
-    }
-
-    snap, err := app.snapshots.Create(message, prepared, timeline.Current)
-    if err != nil {
-        return fmt.Errorf("failed to create snapshot: %v", err)
-    }
-
-    timeline.Current = snap.ID
-    timeline.Snapshots = append(timeline.Snapshots, snap.ID)
-    if err := app.timelines.Update(timeline); err != nil {
-        return fmt.Errorf("failed to update timeline: %v", err)
-    }
-
-    if err := app.index.PrepareFiles(make(map[string]string)); err != nil {
-        return fmt.Errorf("failed to clear prepared files: %v", err)
-    }
-
-    fmt.Printf("Created snapshot: %s\n", snap.ID)
-    return nil
-}
+	timeline, err := app.timelines.GetCurrent()
+	if err != nil {
+		return fmt.Errorf("failed to get current timeline: %v", err)
+	}
+
+	modes, err := app.index.GetPreparedModes()
+	if err != nil {
+		return fmt.Errorf("failed to get prepared modes: %v", err)
+	}
+
+	head, err := app.snapshotOrEmpty(timeline.Current)
+	if err != nil {
+		return err
+	}
+	files, fileModes := snapshot.MergeTree(head.Files, head.Modes, prepared, modes)
+
+	author, committer, err := app.signatures(opts)
+	if err != nil {
+		return err
+	}
+	snap, err := app.snapshots.Build(message, author, committer, files, fileModes, timeline.Current)
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot: %v", err)
+	}
 
-func (app *App) RecallSnapshot(id string) error {
-    snapshot, err := app.snapshots.Get(id)
-    if err != nil {
-        return fmt.Errorf("failed to get snapshot: %v", err)
-    }
-
-    fmt.Printf("Snapshot: %s\n", snapshot.ID)
-    fmt.Printf("Message: %s\n", snapshot.Message)
-    fmt.Printf("Files:\n")
-    
-    for path, hash := range snapshot.Files {
-        content, err := app.contentStore.Get(hash)
-        if err != nil {
-            fmt.Printf("  %s: [error reading content: %v]\n", path, err)
-            continue
-        }
-        fmt.Printf("  %s: %d bytes\n", path, len(content))
-    }
-
-    return nil
+	journal := &captureJournal{Snapshot: snap.ID, Timeline: timeline.Name, Parent: timeline.Current}
+	if err := app.beginCapture(journal); err != nil {
+		return err
+	}
+	if err := app.snapshots.Save(snap); err != nil {
+		return fmt.Errorf("failed to create snapshot: %v", err)
+	}
+	if err := app.completeCapture(journal); err != nil {
+		return err
+	}
+
+	fmt.Printf("Created snapshot: %s\n", snap.ID)
+	return nil
 }
 
-func (app *App) ShowDiff(path string) error {
-
-    prepared, err := app.index.GetPreparedFiles()
-    if err != nil {
-        return fmt.Errorf("failed to get prepared files: %w", err)
-    }
-
-
-    newHash, exists := prepared[path]
-    if !exists {
-        return fmt.Errorf("file not prepared: %s", path)
-    }
-
-
-    timeline, err := app.timelines.GetCurrent()
-    if err != nil {
-        return fmt.Errorf("failed to get current timeline: %w", err)
-    }
-
-
-    if timeline.Current == "" {
-
-        newContent, err := app.contentStore.Get(newHash)
-        if err != nil {
-            return fmt.Errorf("failed to get new content: %w", err)
-        }
-        
-        lines := strings.Split(string(newContent), "\n")
-        for _, line := range lines {
-            if line != "" {
-                fmt.Printf("%s+ %s%s\n", Green, line, Reset)
-            }
-        }
-        return nil
-    }
-
-
-    snapshot, err := app.snapshots.Get(timeline.Current)
-    if err != nil {
-        return fmt.Errorf("failed to get current snapshot: %w", err)
-    }
-
-
-    oldHash, exists := snapshot.Files[path]
-    if !exists {
-
-        newContent, err := app.contentStore.Get(newHash)
-        if err != nil {
-            return fmt.Errorf("failed to get new content: %w", err)
-        }
-        
-        lines := strings.Split(string(newContent), "\n")
-        for _, line := range lines {
-            if line != "" {
-                fmt.Printf("%s+ %s%s\n", Green, line, Reset)
-            }
-        }
-        return nil
-    }
-
-
-    oldContent, err := app.contentStore.Get(oldHash)
-    if err != nil {
-        return fmt.Errorf("failed to get old content: %w", err)
-    }
-
-    newContent, err := app.contentStore.Get(newHash)
-    if err != nil {
-        return fmt.Errorf("failed to get new content: %w", err)
-    }
-
-
-    oldLines := strings.Split(string(oldContent), "\n")
-    newLines := strings.Split(string(newContent), "\n")
-
-
-    steps := diff.SimpleMyers(oldLines, newLines)
-    if steps == nil {
-        return fmt.Errorf("failed to calculate diff")
-    }
-
-
-    var oldIdx, newIdx int
-    for _, step := range steps {
-        switch step.Type {
-        case "keep":
-            if oldIdx < len(oldLines) {
-                fmt.Printf("  %s\n", oldLines[oldIdx])
-            }
-            oldIdx++
-            newIdx++
-        case "delete":
-            if oldIdx < len(oldLines) {
-                fmt.Printf("%s- %s%s\n", Red, oldLines[oldIdx], Reset)
-            }
-            oldIdx++
-        case "add":
-            if newIdx < len(newLines) {
-                fmt.Printf("%s+ %s%s\n", Green, newLines[newIdx], Reset)
-            }
-            newIdx++
-        }
-    }
-
-    return nil
+func (app *App) RecallSnapshot(rev string) error {
+	id, err := app.ResolveRevision(rev)
+	if err != nil {
+		return err
+	}
+	snapshot, err := app.snapshots.Get(id)
+	if err != nil {
+		return fmt.Errorf("failed to get snapshot: %v", err)
+	}
+
+	fmt.Printf("Snapshot: %s\n", snapshot.ID)
+	printSignatures(snapshot)
+	fmt.Printf("Message: %s\n", snapshot.Message)
+	fmt.Printf("Files:\n")
+
+	for path, hash := range snapshot.Files {
+		content, err := app.contentStore.Get(hash)
+		if err != nil {
+			fmt.Printf("  %s: [error reading content: %v]\n", path, err)
+			continue
+		}
+		fmt.Printf("  %s: %d bytes\n", path, len(content))
+	}
+
+	return nil
 }
 
 type Step struct {
-    Type string
+	Type string
 }
+
 func (app *App) Forget(files []string) error {
+	unlock, err := app.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	existing, err := app.index.GetPreparedFiles()
 	if err != nil {
 		return err
@@ -541,41 +511,37 @@ func (app *App) Forget(files []string) error {
 	return nil
 }
 func (app *App) Initialize() error {
-    dirs := []string{
-        ".nora",
-        ".nora/memories",
-        ".nora/timelines",
-        ".nora/index",
-        ".nora/config",
-        ".nora/meta",
-        ".nora/objects",
-    }
-
-    for _, dir := range dirs {
-        if err := utils.CreateDirIfNotExists(dir); err != nil {
-            return fmt.Errorf("failed to create directory %s: %v", dir, err)
-        }
-    }
-
-    if err := app.timelines.Create("main"); err != nil {
-        return err
-    }
-
-
-    cwd, err := os.Getwd()
-    if err != nil {
-        cwd = "current directory"
-    }
-
-    utils.PrintInitMessage(cwd)
-
-    return nil
+	for _, dir := range app.layout.Dirs() {
+		if err := utils.CreateDirIfNotExists(dir); err != nil {
+			return fmt.Errorf("failed to create directory %s: %v", dir, err)
+		}
+	}
+
+	if err := app.timelines.Create("main"); err != nil {
+		return err
+	}
+	if err := app.raiseFormatVersion(storage.CompressedFormatVersion); err != nil {
+		return err
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		cwd = "current directory"
+	}
+
+	utils.PrintInitMessage(cwd)
+
+	return nil
 }
 func New(rootPath string) *App {
-    return &App{
-        contentStore: storage.NewContentStore(rootPath),
-        index:       storage.NewIndex(rootPath),
-        snapshots:   snapshot.NewStore(rootPath),
-        timelines:   timeline.NewManager(rootPath),
-    }
+	layout := layout.New(rootPath)
+	contentStore := storage.NewContentStore(layout)
+	return &App{
+		layout:       layout,
+		contentStore: contentStore,
+		index:        storage.NewIndex(layout),
+		snapshots:    snapshot.NewStore(layout, contentStore),
+		timelines:    timeline.NewManager(layout),
+		color:        utils.IsTerminal(os.Stdout),
+	}
 }
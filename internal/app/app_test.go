@@ -0,0 +1,26 @@
+package app
+
+import (
+	"os"
+	"testing"
+)
+
+// newTestStory initializes a story in a temporary directory and makes it
+// the working directory until the test ends.
+func newTestStory(t *testing.T) *App {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	story := New(StoryDirName)
+	if err := story.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+	return story
+}
@@ -0,0 +1,161 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jolovicdev/nora/internal/core/config"
+)
+
+// loadSettings reads the layered configuration once per command.
+func (app *App) loadSettings() (*config.Config, error) {
+	if app.settings == nil {
+		settings, err := config.Load(app.configSources())
+		if err != nil {
+			return nil, err
+		}
+		app.settings = settings
+	}
+	return app.settings, nil
+}
+
+// setting returns the effective value of key, or "" when it is unset.
+func (app *App) setting(key string) (string, error) {
+	settings, err := app.loadSettings()
+	if err != nil {
+		return "", err
+	}
+	return settings.Get(key), nil
+}
+
+// configSources lists the settings files in effect. An App made with an
+// empty root runs outside any story and has no repo file.
+func (app *App) configSources() []config.Source {
+	if app.layout.Root() == "" {
+		return config.Sources("")
+	}
+	return config.Sources(app.layout.Config())
+}
+
+func (app *App) configPath(scope config.Scope) (string, error) {
+	if scope == config.Repo && app.layout.Root() == "" {
+		return "", fmt.Errorf("not in a nora story; use --user or --system")
+	}
+	for _, source := range app.configSources() {
+		if source.Scope == scope {
+			return source.Path, nil
+		}
+	}
+	return "", fmt.Errorf("no %s config file", scope)
+}
+
+// GetConfig prints the effective value of key and reports whether it is
+// set.
+func (app *App) GetConfig(key string) (bool, error) {
+	if _, err := config.LookupKey(key); err != nil {
+		return false, err
+	}
+	settings, err := app.loadSettings()
+	if err != nil {
+		return false, err
+	}
+	entry, ok := settings.Lookup(key)
+	if ok {
+		fmt.Println(entry.Value)
+	}
+	return ok, nil
+}
+
+// GetScopedConfig prints the value of key in the file of the given scope
+// alone and reports whether it is set there.
+func (app *App) GetScopedConfig(scope config.Scope, key string) (bool, error) {
+	if _, err := config.LookupKey(key); err != nil {
+		return false, err
+	}
+	path, err := app.configPath(scope)
+	if err != nil {
+		return false, err
+	}
+	file, err := config.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	value, ok := file.Get(key)
+	if ok {
+		fmt.Println(value)
+	}
+	return ok, nil
+}
+
+// SetConfig writes key to the file of the given scope. Writing the
+// story's own file takes the story lock.
+func (app *App) SetConfig(scope config.Scope, key, value string) error {
+	path, err := app.configPath(scope)
+	if err != nil {
+		return err
+	}
+	if scope == config.Repo {
+		unlock, err := app.lock()
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+
+	app.settings = nil
+	return config.Set(path, key, value)
+}
+
+func (app *App) UnsetConfig(scope config.Scope, key string) error {
+	path, err := app.configPath(scope)
+	if err != nil {
+		return err
+	}
+	if scope == config.Repo {
+		unlock, err := app.lock()
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+
+	app.settings = nil
+	removed, err := config.Unset(path, key)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return fmt.Errorf("%s is not set in %s", key, path)
+	}
+	return nil
+}
+
+// ListConfig prints every effective setting, optionally prefixed with the
+// scope and file or variable it came from.
+func (app *App) ListConfig(showOrigin bool) error {
+	settings, err := app.loadSettings()
+	if err != nil {
+		return err
+	}
+	for _, entry := range settings.List() {
+		if showOrigin {
+			fmt.Printf("%s:%s\t", entry.Scope, entry.Origin)
+		}
+		fmt.Printf("%s=%s\n", entry.Key, entry.Value)
+	}
+	return nil
+}
+
+// ExpandAlias replaces an aliased command with the words of its alias.
+// Aliases do not expand recursively.
+func (app *App) ExpandAlias(args []string) ([]string, error) {
+	if len(args) == 0 || strings.Contains(args[0], ".") {
+		return args, nil
+	}
+	alias, err := app.setting(config.AliasSection + "." + args[0])
+	if err != nil || alias == "" {
+		return args, err
+	}
+	expanded := strings.Fields(alias)
+	return append(expanded, args[1:]...), nil
+}
@@ -0,0 +1,298 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/jolovicdev/nora/internal/core/diff"
+	"github.com/jolovicdev/nora/internal/core/snapshot"
+	"github.com/jolovicdev/nora/internal/core/storage"
+	"github.com/jolovicdev/nora/internal/utils"
+)
+
+type DiffOptions struct {
+	Revisions []string
+	Paths     []string
+	Prepared  bool
+	Context   int
+	Algorithm string
+	WordDiff  bool
+}
+
+type diffSide struct {
+	files map[string]string
+	load  func(path, hash string) ([]byte, error)
+}
+
+func (app *App) ShowDiff(opts DiffOptions) error {
+	if len(opts.Revisions) > 2 {
+		return fmt.Errorf("too many revisions: %v", opts.Revisions)
+	}
+	if opts.Prepared && len(opts.Revisions) > 1 {
+		return fmt.Errorf("--prepared takes at most one revision")
+	}
+
+	algorithm, err := app.diffAlgorithm(opts.Algorithm)
+	if err != nil {
+		return err
+	}
+
+	var oldSide, newSide *diffSide
+	switch {
+	case len(opts.Revisions) == 2:
+		if oldSide, err = app.snapshotSide(opts.Revisions[0]); err != nil {
+			return err
+		}
+		if newSide, err = app.snapshotSide(opts.Revisions[1]); err != nil {
+			return err
+		}
+	case opts.Prepared:
+		rev := "head"
+		if len(opts.Revisions) == 1 {
+			rev = opts.Revisions[0]
+		}
+		if oldSide, err = app.snapshotSide(rev); err != nil {
+			return err
+		}
+		if newSide, err = app.preparedSide(); err != nil {
+			return err
+		}
+	case len(opts.Revisions) == 1:
+		if oldSide, err = app.snapshotSide(opts.Revisions[0]); err != nil {
+			return err
+		}
+		if newSide, err = app.workingSide(oldSide.files); err != nil {
+			return err
+		}
+	default:
+		if oldSide, err = app.preparedSide(); err != nil {
+			return err
+		}
+		if newSide, err = app.workingSide(oldSide.files); err != nil {
+			return err
+		}
+	}
+
+	attrs, err := app.loadAttributes()
+	if err != nil {
+		return err
+	}
+
+	unified := diff.UnifiedOptions{
+		Context:  opts.Context,
+		Color:    app.color,
+		WordDiff: opts.WordDiff,
+	}
+
+	paths := make(map[string]string)
+	for path, hash := range oldSide.files {
+		paths[path] = hash
+	}
+	for path, hash := range newSide.files {
+		paths[path] = hash
+	}
+
+	for _, pattern := range opts.Paths {
+		if _, err := os.Lstat(pattern); err != nil && len(trackedUnder(paths, filepath.ToSlash(pattern))) == 0 {
+			return fmt.Errorf("unknown revision or path: %s", pattern)
+		}
+	}
+
+	for _, path := range sortedPaths(paths) {
+		if !matchesAny(path, opts.Paths) {
+			continue
+		}
+		oldHash, inOld := oldSide.files[path]
+		newHash, inNew := newSide.files[path]
+		if inOld && inNew && oldHash == newHash {
+			continue
+		}
+
+		oldName, newName := "a/"+path, "b/"+path
+		var oldContent, newContent []byte
+		if inOld {
+			if oldContent, err = oldSide.load(path, oldHash); err != nil {
+				return fmt.Errorf("failed to read old content of %s: %w", path, err)
+			}
+		} else {
+			oldName = "/dev/null"
+		}
+		if inNew {
+			if newContent, err = newSide.load(path, newHash); err != nil {
+				return fmt.Errorf("failed to read new content of %s: %w", path, err)
+			}
+		} else {
+			newName = "/dev/null"
+		}
+
+		if attrs.IsBinary(path, oldContent) || attrs.IsBinary(path, newContent) {
+			fmt.Printf("Binary files %s and %s differ (%s -> %s)\n", oldName, newName,
+				utils.FormatSize(int64(len(oldContent))), utils.FormatSize(int64(len(newContent))))
+			continue
+		}
+
+		oldLines := diff.SplitLines(string(oldContent))
+		newLines := diff.SplitLines(string(newContent))
+		steps := algorithm.Diff(oldLines, newLines)
+		if steps == nil {
+			return fmt.Errorf("failed to calculate diff for %s", path)
+		}
+		fmt.Print(diff.Unified(oldName, newName, oldLines, newLines, steps, unified))
+	}
+	return nil
+}
+
+func (app *App) diffAlgorithm(name string) (diff.Algorithm, error) {
+	if name == "" {
+		var err error
+		if name, err = app.setting("diff.algorithm"); err != nil {
+			return nil, err
+		}
+	}
+	return diff.Lookup(name)
+}
+
+func (app *App) ResolveRevision(rev string) (string, error) {
+	if rev == "head" || rev == "HEAD" {
+		timeline, err := app.timelines.GetCurrent()
+		if err != nil {
+			return "", fmt.Errorf("failed to get current timeline: %w", err)
+		}
+		if timeline.Current == "" {
+			return "", fmt.Errorf("timeline %s has no snapshots yet", timeline.Name)
+		}
+		return timeline.Current, nil
+	}
+
+	if exists, err := app.timelines.Exists(rev); err == nil && exists {
+		timeline, err := app.timelines.Get(rev)
+		if err != nil {
+			return "", err
+		}
+		if timeline.Current == "" {
+			return "", fmt.Errorf("timeline %s has no snapshots yet", rev)
+		}
+		return timeline.Current, nil
+	}
+
+	id, err := app.snapshots.Resolve(rev)
+	if errors.Is(err, snapshot.ErrNotFound) {
+		return "", fmt.Errorf("unknown revision: %s", rev)
+	}
+	if err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// SplitRevisions separates leading revision arguments from paths when the
+// user did not use "--". An argument that names an existing file is always
+// treated as a path; toPath turns path arguments into working tree paths.
+func (app *App) SplitRevisions(args []string, toPath func(string) (string, error)) ([]string, []string, error) {
+	var revisions []string
+	for len(args) > 0 && len(revisions) < 2 {
+		if path, err := toPath(args[0]); err == nil {
+			if _, err := os.Lstat(path); err == nil {
+				break
+			}
+		}
+		if _, err := app.ResolveRevision(args[0]); err != nil {
+			break
+		}
+		revisions = append(revisions, args[0])
+		args = args[1:]
+	}
+
+	paths := make([]string, len(args))
+	for i, arg := range args {
+		var err error
+		if paths[i], err = toPath(arg); err != nil {
+			return nil, nil, err
+		}
+	}
+	return revisions, paths, nil
+}
+
+func (app *App) snapshotSide(rev string) (*diffSide, error) {
+	id, err := app.ResolveRevision(rev)
+	if err != nil {
+		return nil, err
+	}
+	snap, err := app.snapshotOrEmpty(id)
+	if err != nil {
+		return nil, err
+	}
+	return &diffSide{files: snap.Files, load: app.loadObject}, nil
+}
+
+func (app *App) preparedSide() (*diffSide, error) {
+	head, err := app.headSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	prepared, err := app.index.GetPreparedFiles()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get prepared files: %w", err)
+	}
+	files, _ := snapshot.MergeTree(head.Files, head.Modes, prepared, nil)
+	return &diffSide{files: files, load: app.loadObject}, nil
+}
+
+// workingSide reads the working copy of every path tracked by head, the
+// prepared files or the other side, so files added since an older
+// revision show up as new.
+func (app *App) workingSide(other map[string]string) (*diffSide, error) {
+	current, err := app.preparedSide()
+	if err != nil {
+		return nil, err
+	}
+	tracked := make(map[string]bool)
+	for path := range other {
+		tracked[path] = true
+	}
+	for path := range current.files {
+		tracked[path] = true
+	}
+
+	files := make(map[string]string)
+	for path := range tracked {
+		content, _, err := readWorkingFile(path)
+		if err != nil {
+			continue
+		}
+		files[path] = storage.Hash(content)
+	}
+	return &diffSide{
+		files: files,
+		load: func(path, _ string) ([]byte, error) {
+			content, _, err := readWorkingFile(path)
+			return content, err
+		},
+	}, nil
+}
+
+func (app *App) loadAttributes() (*storage.Attributes, error) {
+	attrs, err := storage.LoadAttributes(".noraattributes")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load attributes: %w", err)
+	}
+	return attrs, nil
+}
+
+func (app *App) loadObject(_, hash string) ([]byte, error) {
+	return app.contentStore.Get(hash)
+}
+
+func matchesAny(path string, patterns []string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if pathMatches(path, filepath.ToSlash(pattern)) {
+			return true
+		}
+	}
+	return false
+}
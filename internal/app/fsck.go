@@ -0,0 +1,224 @@
+package app
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/jolovicdev/nora/internal/core/config"
+	"github.com/jolovicdev/nora/internal/core/snapshot"
+)
+
+// Fsck exit codes are bits so a single run can report several kinds of
+// damage; 1 stays reserved for ordinary command failures.
+const (
+	FsckCorruptObject = 1 << (iota + 1)
+	FsckMissingObject
+	FsckBrokenSnapshot
+	FsckBrokenMetadata
+)
+
+type FsckError struct {
+	Code     int
+	Problems int
+}
+
+func (e *FsckError) Error() string {
+	return fmt.Sprintf("fsck found %d problems", e.Problems)
+}
+
+// objects maps every stored hash to whether it verified, so references to
+// corrupt objects are not reported a second time as missing.
+type fsck struct {
+	objects  map[string]bool
+	code     int
+	problems int
+}
+
+func (f *fsck) report(code int, subject, format string, args ...interface{}) {
+	kinds := map[int]string{
+		FsckCorruptObject:  "corrupt-object",
+		FsckMissingObject:  "missing-object",
+		FsckBrokenSnapshot: "broken-snapshot",
+		FsckBrokenMetadata: "broken-metadata",
+	}
+	fmt.Printf("%s %s: %s\n", kinds[code], subject, fmt.Sprintf(format, args...))
+	f.code |= code
+	f.problems++
+}
+
+func (f *fsck) require(hash, format string, args ...interface{}) {
+	if _, ok := f.objects[hash]; !ok {
+		f.report(FsckMissingObject, hash, format, args...)
+	}
+}
+
+// Fsck verifies that every object rehashes to its name, that snapshots
+// only reference objects and parents that exist, and that the state,
+// config, timelines and prepared index parse and point at stored data.
+func (app *App) Fsck() error {
+	f := &fsck{objects: make(map[string]bool)}
+
+	objects, err := app.checkObjects(f)
+	if err != nil {
+		return err
+	}
+	snapshots, err := app.checkSnapshots(f)
+	if err != nil {
+		return err
+	}
+	timelines := app.checkTimelines(f, snapshots)
+	if _, err := config.ReadFile(app.layout.Config()); err != nil {
+		f.report(FsckBrokenMetadata, "config", "%v", err)
+	}
+
+	prepared, err := app.index.GetPreparedFiles()
+	if err != nil {
+		f.report(FsckBrokenMetadata, "index", "%v", err)
+	}
+	for _, path := range sortedPaths(prepared) {
+		if hash := prepared[path]; hash != "" {
+			f.require(hash, "prepared file %s", path)
+		}
+	}
+
+	if f.problems > 0 {
+		return &FsckError{Code: f.code, Problems: f.problems}
+	}
+	fmt.Printf("No problems found (%d objects, %d snapshots, %d timelines)\n", objects, len(snapshots), timelines)
+	return nil
+}
+
+func (app *App) checkObjects(f *fsck) (int, error) {
+	for pack, err := range app.contentStore.VerifyPacks() {
+		f.report(FsckCorruptObject, pack, "%v", err)
+	}
+
+	loose, err := app.contentStore.LooseHashes()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list loose objects: %w", err)
+	}
+	packed, err := app.contentStore.PackedHashes()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list packed objects: %w", err)
+	}
+
+	hashes := append(loose, packed...)
+	sort.Strings(hashes)
+	for _, hash := range hashes {
+		if _, ok := f.objects[hash]; ok {
+			continue
+		}
+		err := app.contentStore.Verify(hash)
+		if err != nil {
+			f.report(FsckCorruptObject, hash, "%v", err)
+		}
+		f.objects[hash] = err == nil
+	}
+	return len(f.objects), nil
+}
+
+func (app *App) checkSnapshots(f *fsck) (map[string]string, error) {
+	ids, err := app.snapshots.IDs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+	sort.Strings(ids)
+
+	parents := make(map[string]string)
+	for _, id := range ids {
+		snap, err := app.snapshots.Load(id)
+		if err != nil {
+			f.report(FsckBrokenSnapshot, id, "%v", err)
+			continue
+		}
+		if snap.ID != id {
+			f.report(FsckBrokenSnapshot, id, "stored with id %s", snap.ID)
+		} else if snapshot.ContentAddressed(id) && snapshot.ComputeID(snap) != id {
+			f.report(FsckBrokenSnapshot, id, "content hashes to %s", snapshot.ComputeID(snap))
+		}
+		parents[id] = snap.Parent
+
+		files := snap.Files
+		if snap.Tree != "" {
+			if valid, ok := f.objects[snap.Tree]; !valid {
+				if !ok {
+					f.report(FsckMissingObject, snap.Tree, "tree of snapshot %s", id)
+				}
+				continue
+			}
+			if files, _, err = app.snapshots.ReadTree(snap.Tree); err != nil {
+				f.report(FsckBrokenSnapshot, id, "unreadable tree %s: %v", snap.Tree, err)
+				continue
+			}
+		}
+		for _, path := range sortedPaths(files) {
+			if hash := files[path]; hash != "" {
+				f.require(hash, "file %s in snapshot %s", path, id)
+			}
+		}
+	}
+
+	terminates := make(map[string]bool)
+	for _, id := range ids {
+		if _, ok := parents[id]; !ok {
+			continue
+		}
+		var chain []string
+		seen := make(map[string]bool)
+		current := id
+		for current != "" && !terminates[current] {
+			if seen[current] {
+				f.report(FsckBrokenSnapshot, id, "parent chain loops at %s", current)
+				break
+			}
+			parent, ok := parents[current]
+			if !ok {
+				f.report(FsckBrokenSnapshot, id, "parent chain reaches missing snapshot %s", current)
+				break
+			}
+			seen[current] = true
+			chain = append(chain, current)
+			current = parent
+		}
+		if current == "" || terminates[current] {
+			for _, snap := range chain {
+				terminates[snap] = true
+			}
+		}
+	}
+	return parents, nil
+}
+
+func (app *App) checkTimelines(f *fsck, snapshots map[string]string) int {
+	config, err := app.timelines.Config()
+	if err != nil {
+		f.report(FsckBrokenMetadata, "state", "%v", err)
+		return 0
+	}
+	if _, ok := config.Timelines[config.CurrentTimeline]; !ok {
+		f.report(FsckBrokenMetadata, "state", "current timeline %q does not exist", config.CurrentTimeline)
+	}
+
+	names := make([]string, 0, len(config.Timelines))
+	for name := range config.Timelines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		timeline, err := app.timelines.Get(name)
+		if err != nil {
+			f.report(FsckBrokenMetadata, "timeline "+name, "%v", err)
+			continue
+		}
+		if _, ok := snapshots[timeline.Current]; timeline.Current != "" && !ok {
+			f.report(FsckBrokenMetadata, "timeline "+name, "points at missing snapshot %s", timeline.Current)
+		}
+		for _, id := range timeline.Snapshots {
+			if _, ok := snapshots[id]; !ok && id != timeline.Current {
+				f.report(FsckBrokenMetadata, "timeline "+name, "lists missing snapshot %s", id)
+			}
+		}
+	}
+	return len(names)
+}
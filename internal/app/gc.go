@@ -0,0 +1,210 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/jolovicdev/nora/internal/utils"
+)
+
+const DefaultGCGrace = time.Hour
+
+type GCOptions struct {
+	DryRun bool
+	Grace  time.Duration
+	Pack   bool
+}
+
+func (app *App) GarbageCollect(opts GCOptions) error {
+	unlock, err := app.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	live, reachable, err := app.reachableObjects()
+	if err != nil {
+		return err
+	}
+	cutoff := time.Now().Add(-opts.Grace)
+
+	prunedSnapshots, err := app.pruneSnapshots(live, reachable, cutoff, opts.DryRun)
+	if err != nil {
+		return err
+	}
+
+	loose, err := app.contentStore.LooseHashes()
+	if err != nil {
+		return fmt.Errorf("failed to list loose objects: %w", err)
+	}
+	packed, err := app.contentStore.PackedHashes()
+	if err != nil {
+		return fmt.Errorf("failed to list packed objects: %w", err)
+	}
+
+	var keepLoose []string
+	var removed int
+	var removedBytes int64
+	for _, hash := range loose {
+		modTime, size, err := app.contentStore.ObjectTime(hash)
+		if err != nil {
+			return fmt.Errorf("failed to stat object %s: %w", hash, err)
+		}
+		if reachable[hash] || modTime.After(cutoff) {
+			keepLoose = append(keepLoose, hash)
+			continue
+		}
+
+		if opts.DryRun {
+			fmt.Printf("Would remove %s (%s)\n", hash, utils.FormatSize(size))
+		} else if err := app.contentStore.RemoveLoose(hash); err != nil {
+			return fmt.Errorf("failed to remove object %s: %w", hash, err)
+		}
+		removed++
+		removedBytes += size
+	}
+
+	var keepPacked []string
+	droppedPacked := 0
+	for _, hash := range packed {
+		modTime, _, err := app.contentStore.ObjectTime(hash)
+		if err != nil {
+			return fmt.Errorf("failed to stat object %s: %w", hash, err)
+		}
+		if reachable[hash] || modTime.After(cutoff) {
+			keepPacked = append(keepPacked, hash)
+			continue
+		}
+		if opts.DryRun {
+			fmt.Printf("Would drop packed %s\n", hash)
+		}
+		droppedPacked++
+	}
+
+	verb := "Removed"
+	if opts.DryRun {
+		verb = "Would remove"
+	}
+	fmt.Printf("%s %d unreachable snapshots, %d loose objects (%s) and %d packed objects\n",
+		verb, prunedSnapshots, removed, utils.FormatSize(removedBytes), droppedPacked)
+	if opts.DryRun {
+		return nil
+	}
+
+	// Repacking writes exactly the kept objects, so it is also how
+	// unreachable packed objects are dropped.
+	keep := keepPacked
+	if opts.Pack {
+		keep = append(keep, keepLoose...)
+	} else if droppedPacked == 0 {
+		return nil
+	}
+	if len(keep) > 0 {
+		if err := app.usePacks(); err != nil {
+			return err
+		}
+	}
+	stats, err := app.contentStore.Repack(keep)
+	if err != nil {
+		return fmt.Errorf("failed to repack objects: %w", err)
+	}
+	if opts.Pack {
+		fmt.Printf("Packed %d objects (%d as deltas)\n", stats.Objects, stats.Deltas)
+	}
+	return nil
+}
+
+// reachableObjects walks from every timeline head through parent chains
+// and returns the snapshots found along with every tree and blob they or
+// the prepared index refer to.
+func (app *App) reachableObjects() (map[string]bool, map[string]bool, error) {
+	live := make(map[string]bool)
+	reachable := make(map[string]bool)
+
+	timelines, err := app.timelines.List()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list timelines: %w", err)
+	}
+	for _, timeline := range timelines {
+		if err := app.markReachable(timeline.Current, live, reachable); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	prepared, err := app.index.GetPreparedFiles()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get prepared files: %w", err)
+	}
+	for _, hash := range prepared {
+		if hash != "" {
+			reachable[hash] = true
+		}
+	}
+	return live, reachable, nil
+}
+
+// markReachable marks id, its ancestors and everything their trees refer
+// to, stopping at snapshots already marked.
+func (app *App) markReachable(id string, live, reachable map[string]bool) error {
+	for id != "" && !live[id] {
+		snap, err := app.snapshots.Get(id)
+		if err != nil {
+			return fmt.Errorf("failed to read snapshot %s: %w", id, err)
+		}
+		live[id] = true
+		if snap.Tree != "" {
+			reachable[snap.Tree] = true
+		}
+		for _, hash := range snap.Files {
+			reachable[hash] = true
+		}
+		id = snap.Parent
+	}
+	return nil
+}
+
+// pruneSnapshots deletes snapshots no timeline leads to, such as those of
+// a force-deleted timeline, once they are older than cutoff. Younger ones
+// are kept along with their ancestors, and everything they refer to is
+// added to reachable so their objects survive too.
+func (app *App) pruneSnapshots(live, reachable map[string]bool, cutoff time.Time, dryRun bool) (int, error) {
+	ids, err := app.snapshots.IDs()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+	sort.Strings(ids)
+
+	var unreachable []string
+	for _, id := range ids {
+		if live[id] {
+			continue
+		}
+		info, err := os.Stat(app.layout.Memory(id))
+		if err != nil {
+			return 0, fmt.Errorf("failed to stat snapshot %s: %w", id, err)
+		}
+		if info.ModTime().After(cutoff) {
+			if err := app.markReachable(id, live, reachable); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		unreachable = append(unreachable, id)
+	}
+
+	pruned := 0
+	for _, id := range unreachable {
+		if live[id] {
+			continue
+		}
+		if dryRun {
+			fmt.Printf("Would remove snapshot %s\n", id)
+		} else if err := os.Remove(app.layout.Memory(id)); err != nil {
+			return pruned, fmt.Errorf("failed to remove snapshot %s: %w", id, err)
+		}
+		pruned++
+	}
+	return pruned, nil
+}
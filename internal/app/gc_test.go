@@ -0,0 +1,102 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jolovicdev/nora/internal/core/storage"
+)
+
+// age sets the modification time of every file below dir to an hour
+// before the gc grace period ends.
+func age(t *testing.T, dir string) {
+	t.Helper()
+	old := time.Now().Add(-2 * time.Hour)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		return os.Chtimes(path, old, old)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func capture(t *testing.T, story *App, file, content string) string {
+	t.Helper()
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := story.PrepareFiles([]string{file}); err != nil {
+		t.Fatal(err)
+	}
+	if err := story.CreateSnapshot("add "+file, CaptureOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	timeline, err := story.timelines.GetCurrent()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return timeline.Current
+}
+
+func TestGCKeepsObjectsOfSnapshotsInGracePeriod(t *testing.T) {
+	story := newTestStory(t)
+	capture(t, story, "main.txt", "on main\n")
+	if err := story.CreateTimeline("side", true); err != nil {
+		t.Fatal(err)
+	}
+	sideBase := capture(t, story, "side1.txt", "first on side\n")
+	sideHead := capture(t, story, "side2.txt", "second on side\n")
+	if err := story.SwitchTimeline("main", false); err != nil {
+		t.Fatal(err)
+	}
+	if err := story.DeleteTimeline("side", true); err != nil {
+		t.Fatal(err)
+	}
+	sideBlob := storage.Hash([]byte("first on side\n"))
+
+	// The side snapshots are still in their grace period, but the blobs
+	// they alone refer to are not.
+	age(t, story.layout.ObjectsDir())
+	age(t, story.layout.MemoriesDir())
+	now := time.Now()
+	if err := os.Chtimes(story.layout.Memory(sideHead), now, now); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := story.GarbageCollect(GCOptions{Grace: time.Hour}); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{sideHead, sideBase} {
+		if _, err := os.Stat(story.layout.Memory(id)); err != nil {
+			t.Errorf("snapshot %s in the grace period or leading to one was removed: %v", id, err)
+		}
+	}
+	if has, err := story.contentStore.Has(sideBlob); err != nil || !has {
+		t.Errorf("blob of a kept snapshot was removed: %v", err)
+	}
+	if err := story.Fsck(); err != nil {
+		t.Errorf("fsck after gc: %v", err)
+	}
+
+	// Once the grace period is over everything on side goes.
+	age(t, story.layout.MemoriesDir())
+	if err := story.GarbageCollect(GCOptions{Grace: time.Hour, Pack: true}); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{sideHead, sideBase} {
+		if _, err := os.Stat(story.layout.Memory(id)); !os.IsNotExist(err) {
+			t.Errorf("unreachable snapshot %s survived: %v", id, err)
+		}
+	}
+	if has, err := story.contentStore.Has(sideBlob); err != nil || has {
+		t.Errorf("blob only side referred to survived: %v, %v", has, err)
+	}
+	if err := story.Fsck(); err != nil {
+		t.Errorf("fsck after gc: %v", err)
+	}
+}
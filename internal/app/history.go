@@ -0,0 +1,191 @@
+package app
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jolovicdev/nora/internal/core/diff"
+	"github.com/jolovicdev/nora/internal/types"
+)
+
+type HistoryOptions struct {
+	Since   time.Time
+	Until   time.Time
+	Paths   []string
+	Grep    string
+	Limit   int
+	OneLine bool
+}
+
+type changeSummary struct {
+	Added    []string
+	Modified []string
+	Deleted  []string
+	Renames  []types.Rename
+}
+
+func (app *App) ShowHistory(opts HistoryOptions) error {
+	var grep *regexp.Regexp
+	if opts.Grep != "" {
+		var err error
+		grep, err = regexp.Compile(opts.Grep)
+		if err != nil {
+			return fmt.Errorf("invalid --grep pattern: %w", err)
+		}
+	}
+
+	timeline, err := app.timelines.GetCurrent()
+	if err != nil {
+		return fmt.Errorf("failed to get current timeline: %w", err)
+	}
+	if timeline.Current == "" {
+		fmt.Printf("Timeline %s has no snapshots yet\n", timeline.Name)
+		return nil
+	}
+
+	shown := 0
+	seen := make(map[string]bool)
+	snap, err := app.snapshotOrEmpty(timeline.Current)
+	if err != nil {
+		return err
+	}
+	for snap != nil {
+		if seen[snap.ID] {
+			return fmt.Errorf("snapshot %s has a cyclic parent chain", snap.ID)
+		}
+		seen[snap.ID] = true
+
+		var parent *types.Snapshot
+		if snap.Parent != "" {
+			parent, err = app.snapshotOrEmpty(snap.Parent)
+			if err != nil {
+				return err
+			}
+		}
+
+		when := time.Unix(snap.Timestamp, 0)
+		if historyMatches(snap, parent, when, grep, opts) {
+			app.printHistoryEntry(snap, parent, when, opts.OneLine)
+			shown++
+			if opts.Limit > 0 && shown >= opts.Limit {
+				break
+			}
+		}
+		snap = parent
+	}
+	return nil
+}
+
+func historyMatches(snap, parent *types.Snapshot, when time.Time, grep *regexp.Regexp, opts HistoryOptions) bool {
+	if !opts.Since.IsZero() && when.Before(opts.Since) {
+		return false
+	}
+	if !opts.Until.IsZero() && when.After(opts.Until) {
+		return false
+	}
+	if grep != nil && !grep.MatchString(snap.Message) {
+		return false
+	}
+	if len(opts.Paths) == 0 {
+		return true
+	}
+
+	for _, path := range changedPaths(snap, parent) {
+		for _, p := range opts.Paths {
+			if pathMatches(path, p) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func changedPaths(snap, parent *types.Snapshot) []string {
+	var paths []string
+	parentFiles := map[string]string{}
+	if parent != nil {
+		parentFiles = parent.Files
+	}
+	for path, hash := range snap.Files {
+		if parentFiles[path] != hash {
+			paths = append(paths, path)
+		}
+	}
+	for path := range parentFiles {
+		if _, ok := snap.Files[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+func (app *App) summarizeChanges(snap, parent *types.Snapshot) changeSummary {
+	var summary changeSummary
+	parentFiles := map[string]string{}
+	if parent != nil {
+		parentFiles = parent.Files
+	}
+
+	added := make(map[string]string)
+	deleted := make(map[string]string)
+	for path, hash := range snap.Files {
+		old, ok := parentFiles[path]
+		switch {
+		case !ok:
+			added[path] = hash
+		case old != hash:
+			summary.Modified = append(summary.Modified, path)
+		}
+	}
+	for path, hash := range parentFiles {
+		if _, ok := snap.Files[path]; !ok {
+			deleted[path] = hash
+		}
+	}
+
+	summary.Renames = diff.DetectRenames(deleted, added, app.contentStore.Get, diff.DefaultRenameThreshold)
+	for _, rename := range summary.Renames {
+		delete(deleted, rename.From)
+		delete(added, rename.To)
+	}
+	summary.Added = sortedPaths(added)
+	summary.Deleted = sortedPaths(deleted)
+	sort.Strings(summary.Modified)
+	return summary
+}
+
+func (app *App) printHistoryEntry(snap, parent *types.Snapshot, when time.Time, oneLine bool) {
+	summary := app.summarizeChanges(snap, parent)
+
+	if oneLine {
+		renamed := ""
+		if len(summary.Renames) > 0 {
+			renamed = fmt.Sprintf(", %d renamed", len(summary.Renames))
+		}
+		fmt.Printf("%s%s%s %s %s %s(+%d ~%d -%d%s)%s\n",
+			Yellow, shortID(snap.ID), Reset,
+			when.Format("2006-01-02"),
+			firstLine(snap.Message),
+			Gray, len(summary.Added), len(summary.Modified), len(summary.Deleted), renamed, Reset)
+		return
+	}
+
+	fmt.Printf("%ssnapshot %s%s\n", Yellow, snap.ID, Reset)
+	if snap.Author != nil {
+		printSignatures(snap)
+	} else {
+		fmt.Printf("Date:   %s\n", when.Format(dateFormat))
+	}
+	fmt.Printf("Files:  %d added, %d modified, %d deleted, %d renamed\n", len(summary.Added), len(summary.Modified), len(summary.Deleted), len(summary.Renames))
+	for _, rename := range summary.Renames {
+		fmt.Printf("        renamed: %s -> %s\n", rename.From, rename.To)
+	}
+	fmt.Println()
+	for _, line := range strings.Split(snap.Message, "\n") {
+		fmt.Printf("    %s\n", line)
+	}
+	fmt.Println()
+}
@@ -0,0 +1,80 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jolovicdev/nora/internal/core/ignore"
+)
+
+var defaultIgnores = []string{
+	".nora",
+	".noraignore",
+	".git",
+	".svn",
+	"node_modules",
+	"*.tmp",
+	"*.swp",
+	"nora",
+}
+
+// ignoreMatcher builds the matcher for the working tree. Patterns from the
+// ignore.patterns setting come after the defaults and before .noraignore.
+func (app *App) ignoreMatcher() (*ignore.Matcher, error) {
+	patterns, err := app.setting("ignore.patterns")
+	if err != nil {
+		return nil, err
+	}
+	matcher, err := ignore.New(".", defaultIgnores)
+	if err != nil {
+		return nil, err
+	}
+	if err := matcher.Add("ignore.patterns", strings.Fields(patterns)); err != nil {
+		return nil, err
+	}
+	return matcher, nil
+}
+
+// CheckIgnore prints the rule deciding each path, including negations
+// that re-include it, and reports whether any path is ignored. Tracked
+// and prepared files, and the directories leading to them, are never
+// ignored, so no rule is printed for them.
+func (app *App) CheckIgnore(paths []string) (bool, error) {
+	matcher, err := app.ignoreMatcher()
+	if err != nil {
+		return false, fmt.Errorf("failed to load ignore patterns: %w", err)
+	}
+	head, err := app.headSnapshot()
+	if err != nil {
+		return false, err
+	}
+	prepared, err := app.index.GetPreparedFiles()
+	if err != nil {
+		return false, fmt.Errorf("failed to get prepared files: %w", err)
+	}
+	tracked := trackedPaths(head.Files, prepared)
+
+	anyIgnored := false
+	for _, path := range paths {
+		if tracked[filepath.ToSlash(filepath.Clean(path))] {
+			continue
+		}
+		info, err := os.Lstat(path)
+		isDir := err == nil && info.IsDir()
+
+		rule, err := matcher.Match(path, isDir)
+		if err != nil {
+			return false, err
+		}
+		if rule == nil {
+			continue
+		}
+		if !rule.Negate {
+			anyIgnored = true
+		}
+		fmt.Printf("%s\t%s\n", rule, path)
+	}
+	return anyIgnored, nil
+}
@@ -0,0 +1,91 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFiles(t *testing.T, files ...string) {
+	t.Helper()
+	for _, file := range files {
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(file+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// check-ignore must agree with the walk status and prepare use: a path
+// is reported ignored exactly when the walk leaves it out.
+func TestCheckIgnoreAgreesWithWalk(t *testing.T) {
+	story := newTestStory(t)
+	writeFiles(t, "tracked.log", "build/tracked.txt")
+	if err := story.PrepareFiles([]string{"tracked.log", "build/tracked.txt"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := story.CreateSnapshot("tracked files", CaptureOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, "prepared.log")
+	if err := story.PrepareFiles([]string{"prepared.log"}); err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, "other.log", "build/new.txt", "src/main.go", "src/debug.log")
+	if err := os.WriteFile(".noraignore", []byte("*.log\nbuild/\n!src/debug.log\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	head, err := story.headSnapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	prepared, err := story.index.GetPreparedFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	matcher, err := story.ignoreMatcher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	walked := make(map[string]bool)
+	skip := walkFilter(StoryDirName, matcher, head.Files, prepared)
+	err = story.walkWorkingTree(".", skip, nil, nil, func(file *workingFile) error {
+		walked[file.path] = true
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path    string
+		ignored bool
+	}{
+		{"tracked.log", false},
+		{"prepared.log", false},
+		{"build/tracked.txt", false},
+		{"other.log", true},
+		{"build/new.txt", true},
+		{"src/main.go", false},
+		{"src/debug.log", false},
+	}
+	for _, tt := range tests {
+		ignored, err := story.CheckIgnore([]string{tt.path})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ignored != tt.ignored {
+			t.Errorf("check-ignore %s = %v, want %v", tt.path, ignored, tt.ignored)
+		}
+		if ignored == walked[tt.path] {
+			t.Errorf("check-ignore %s = %v, but the walk visited it: %v", tt.path, ignored, walked[tt.path])
+		}
+	}
+
+	if ignored, err := story.CheckIgnore([]string{"build"}); err != nil || ignored {
+		t.Errorf("check-ignore build = %v, %v; want false since it holds a tracked file", ignored, err)
+	}
+}
@@ -0,0 +1,126 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/jolovicdev/nora/internal/core/lock"
+	"github.com/jolovicdev/nora/internal/utils"
+)
+
+// A capture writes the snapshot, advances the timeline and clears the
+// prepared files. The journal is written before the first of these and
+// removed after the last, so a capture that was interrupted can be told
+// apart on the next run: if its snapshot was saved the remaining steps
+// are replayed, otherwise the journal is dropped and nothing changed.
+// Parent is the timeline head the capture started from; steps are only
+// replayed while the timeline still points there.
+type captureJournal struct {
+	Snapshot string `json:"snapshot"`
+	Timeline string `json:"timeline"`
+	Parent   string `json:"parent"`
+}
+
+func (app *App) beginCapture(journal *captureJournal) error {
+	data, err := json.MarshalIndent(journal, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := utils.CreateDirIfNotExists(app.layout.MetaDir()); err != nil {
+		return err
+	}
+	if err := utils.WriteFileAtomic(app.layout.CaptureJournal(), data, 0644); err != nil {
+		return fmt.Errorf("failed to write capture journal: %w", err)
+	}
+	return nil
+}
+
+// completeCapture performs every step after saving the snapshot. Each
+// step checks what is already done, so it is safe to repeat.
+func (app *App) completeCapture(journal *captureJournal) error {
+	timeline, err := app.timelines.Get(journal.Timeline)
+	if err != nil {
+		return fmt.Errorf("failed to get timeline: %v", err)
+	}
+	if timeline.Current != journal.Snapshot {
+		timeline.Current = journal.Snapshot
+		timeline.Snapshots = append(timeline.Snapshots, journal.Snapshot)
+		if err := app.timelines.Update(timeline); err != nil {
+			return fmt.Errorf("failed to update timeline: %v", err)
+		}
+	}
+
+	if err := app.index.PrepareFiles(make(map[string]string)); err != nil {
+		return fmt.Errorf("failed to clear prepared files: %v", err)
+	}
+	if err := app.index.PrepareModes(make(map[string]os.FileMode)); err != nil {
+		return fmt.Errorf("failed to clear prepared modes: %v", err)
+	}
+
+	if err := os.Remove(app.layout.CaptureJournal()); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove capture journal: %w", err)
+	}
+	return nil
+}
+
+// recoverCapture finishes or discards a capture that was interrupted. When
+// another process holds the lock its capture may still be running, so
+// the journal is left alone.
+func (app *App) recoverCapture() error {
+	if _, err := os.Stat(app.layout.CaptureJournal()); os.IsNotExist(err) {
+		return nil
+	}
+
+	unlock, err := app.lock()
+	var held *lock.HeldError
+	if errors.As(err, &held) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	data, err := os.ReadFile(app.layout.CaptureJournal())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read capture journal: %w", err)
+	}
+	var journal captureJournal
+	if err := json.Unmarshal(data, &journal); err != nil {
+		return fmt.Errorf("failed to parse capture journal: %w", err)
+	}
+
+	if _, err := os.Stat(app.layout.Memory(journal.Snapshot)); os.IsNotExist(err) {
+		return app.rollBackCapture()
+	}
+
+	// Replaying is only right while the timeline is where the capture
+	// left it; if it was moved or deleted since, the snapshot would land
+	// on top of someone else's history.
+	timeline, err := app.timelines.Get(journal.Timeline)
+	if err != nil || (timeline.Current != journal.Parent && timeline.Current != journal.Snapshot) {
+		if err := os.Remove(app.layout.Memory(journal.Snapshot)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove snapshot %s: %w", journal.Snapshot, err)
+		}
+		return app.rollBackCapture()
+	}
+
+	if err := app.completeCapture(&journal); err != nil {
+		return err
+	}
+	fmt.Printf("Completed interrupted capture of snapshot %s\n", journal.Snapshot)
+	return nil
+}
+
+func (app *App) rollBackCapture() error {
+	if err := os.Remove(app.layout.CaptureJournal()); err != nil {
+		return fmt.Errorf("failed to remove capture journal: %w", err)
+	}
+	fmt.Println("Rolled back an interrupted capture")
+	return nil
+}
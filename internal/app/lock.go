@@ -0,0 +1,27 @@
+package app
+
+import (
+	"github.com/jolovicdev/nora/internal/core/lock"
+)
+
+// lock takes the story lock for a command that changes the story. Calls
+// nest, so commands built from other locking commands only lock once.
+func (app *App) lock() (func(), error) {
+	if app.lockDepth == 0 {
+		held, err := lock.Acquire(app.layout.Lock())
+		if err != nil {
+			return nil, err
+		}
+		app.storyLock = held
+	}
+	app.lockDepth++
+	return app.unlock, nil
+}
+
+func (app *App) unlock() {
+	app.lockDepth--
+	if app.lockDepth == 0 {
+		app.storyLock.Release()
+		app.storyLock = nil
+	}
+}
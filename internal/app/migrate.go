@@ -0,0 +1,111 @@
+package app
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/jolovicdev/nora/internal/core/storage"
+)
+
+// Open prepares an existing story for use: it moves files left behind by
+// older layouts into place, refuses formats newer than this build and
+// settles a capture that was interrupted.
+func (app *App) Open() error {
+	if app.layout.HasNested() || app.layout.HasConfigDir() {
+		unlock, err := app.lock()
+		if err != nil {
+			return err
+		}
+		err = app.migrateLayout()
+		unlock()
+		if err != nil {
+			return err
+		}
+	}
+	if err := app.CheckFormat(); err != nil {
+		return err
+	}
+	return app.recoverCapture()
+}
+
+func (app *App) migrateLayout() error {
+	moved, err := app.layout.MigrateNested()
+	if err != nil {
+		return err
+	}
+	if moved {
+		fmt.Printf("Moved config and timelines out of %s\n", filepath.Join(app.layout.Root(), ".nora"))
+	}
+	if !app.layout.HasConfigDir() {
+		return nil
+	}
+	return app.layout.MigrateConfigDir()
+}
+
+// raiseFormatVersion records version unless the story is already at a
+// later one; lowering it would let older builds open a story they cannot
+// read.
+func (app *App) raiseFormatVersion(version int) error {
+	config, err := app.timelines.Config()
+	if err != nil {
+		return err
+	}
+	if config.FormatVersion >= version {
+		return nil
+	}
+	return app.timelines.SetFormatVersion(version)
+}
+
+func (app *App) CheckFormat() error {
+	config, err := app.timelines.Config()
+	if err != nil {
+		return err
+	}
+	if config.FormatVersion > storage.FormatVersion {
+		return fmt.Errorf("repository format version %d is newer than this nora supports (%d); please upgrade nora", config.FormatVersion, storage.FormatVersion)
+	}
+	return nil
+}
+
+func (app *App) Migrate() error {
+	unlock, err := app.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	config, err := app.timelines.Config()
+	if err != nil {
+		return err
+	}
+	if config.FormatVersion >= storage.CompressedFormatVersion {
+		fmt.Printf("Repository is already at format version %d\n", config.FormatVersion)
+		return nil
+	}
+
+	ids, err := app.snapshots.IDs()
+	if err != nil {
+		return fmt.Errorf("failed to list snapshots: %w", err)
+	}
+	kinds := make(map[string]string)
+	for _, id := range ids {
+		snap, err := app.snapshots.Get(id)
+		if err != nil {
+			return fmt.Errorf("failed to read snapshot %s: %w", id, err)
+		}
+		if snap.Tree != "" {
+			kinds[snap.Tree] = storage.TreeObject
+		}
+	}
+
+	migrated, err := app.contentStore.Migrate(kinds)
+	if err != nil {
+		return fmt.Errorf("failed to migrate objects: %w", err)
+	}
+	if err := app.raiseFormatVersion(storage.CompressedFormatVersion); err != nil {
+		return err
+	}
+
+	fmt.Printf("Compressed %d objects, repository is now at format version %d\n", migrated, storage.CompressedFormatVersion)
+	return nil
+}
@@ -0,0 +1,92 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jolovicdev/nora/internal/core/storage"
+)
+
+func formatVersion(t *testing.T, story *App) int {
+	t.Helper()
+	config, err := story.timelines.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return config.FormatVersion
+}
+
+func TestMigrateCompressesRawObjects(t *testing.T) {
+	story := newTestStory(t)
+	if err := story.timelines.SetFormatVersion(0); err != nil {
+		t.Fatal(err)
+	}
+
+	content := []byte("written by format version 0\n")
+	hash := storage.Hash(content)
+	path := story.layout.Object(hash)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := story.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	if got := formatVersion(t, story); got != storage.CompressedFormatVersion {
+		t.Errorf("format version %d after migrate, want %d", got, storage.CompressedFormatVersion)
+	}
+	onDisk, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(onDisk) == string(content) {
+		t.Error("object is still stored raw")
+	}
+	if got, err := story.contentStore.Get(hash); err != nil || string(got) != string(content) {
+		t.Errorf("read back %q, %v", got, err)
+	}
+}
+
+func TestCheckFormatRefusesNewerStories(t *testing.T) {
+	story := newTestStory(t)
+	if err := story.CheckFormat(); err != nil {
+		t.Fatalf("new story: %v", err)
+	}
+	if err := story.timelines.SetFormatVersion(storage.FormatVersion + 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := story.CheckFormat(); err == nil {
+		t.Error("opened a story from a newer version")
+	}
+}
+
+func TestFormatVersionNeverDrops(t *testing.T) {
+	story := newTestStory(t)
+	if got := formatVersion(t, story); got != storage.CompressedFormatVersion {
+		t.Fatalf("new story at format version %d, want %d", got, storage.CompressedFormatVersion)
+	}
+
+	if _, err := story.contentStore.Store([]byte("packed\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := story.Pack(false); err != nil {
+		t.Fatal(err)
+	}
+	if got := formatVersion(t, story); got != storage.PackedFormatVersion {
+		t.Fatalf("format version %d after packing, want %d", got, storage.PackedFormatVersion)
+	}
+
+	if err := story.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+	if err := story.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	if got := formatVersion(t, story); got != storage.PackedFormatVersion {
+		t.Errorf("format version %d after init and migrate, want %d", got, storage.PackedFormatVersion)
+	}
+}
@@ -0,0 +1,84 @@
+package app
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/jolovicdev/nora/internal/utils"
+)
+
+// SetupOutput applies color.ui and, when page is set and stdout is a
+// terminal, sends stdout through core.pager. The returned func closes the
+// pager and waits for the user to leave it.
+func (app *App) SetupOutput(page bool) (func(), error) {
+	settings, err := app.loadSettings()
+	if err != nil {
+		return nil, err
+	}
+
+	terminal := utils.IsTerminal(os.Stdout)
+	switch strings.ToLower(settings.Get("color.ui")) {
+	case "always":
+		app.color = true
+	case "never":
+		app.color = false
+	default:
+		app.color = terminal
+	}
+	if !app.color {
+		disableColors()
+	}
+
+	if !page || !terminal {
+		return func() {}, nil
+	}
+	pager := os.Getenv("PAGER")
+	if entry, ok := settings.Lookup("core.pager"); ok {
+		pager = entry.Value
+	} else if pager == "" {
+		pager = "less"
+	}
+	return startPager(pager), nil
+}
+
+func disableColors() {
+	for _, color := range []*string{&Reset, &Red, &Green, &Yellow, &Blue, &Magenta, &Cyan, &Gray, &White} {
+		*color = ""
+	}
+}
+
+// startPager runs pager with stdout redirected into it. A pager that
+// cannot be started leaves stdout alone.
+func startPager(pager string) func() {
+	pager = strings.TrimSpace(pager)
+	if pager == "" || pager == "cat" {
+		return func() {}
+	}
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return func() {}
+	}
+	cmd := exec.Command("sh", "-c", pager)
+	cmd.Stdin = reader
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if os.Getenv("LESS") == "" {
+		cmd.Env = append(os.Environ(), "LESS=FRX")
+	}
+	if err := cmd.Start(); err != nil {
+		reader.Close()
+		writer.Close()
+		return func() {}
+	}
+	reader.Close()
+
+	stdout := os.Stdout
+	os.Stdout = writer
+	return func() {
+		os.Stdout = stdout
+		writer.Close()
+		cmd.Wait()
+	}
+}
@@ -0,0 +1,46 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/jolovicdev/nora/internal/core/storage"
+	"github.com/jolovicdev/nora/internal/utils"
+)
+
+func (app *App) Pack(all bool) error {
+	unlock, err := app.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	loose, err := app.contentStore.LooseHashes()
+	if err != nil {
+		return fmt.Errorf("failed to list objects: %w", err)
+	}
+	if len(loose) > 0 || all {
+		if err := app.usePacks(); err != nil {
+			return err
+		}
+	}
+
+	stats, err := app.contentStore.Pack(all)
+	if err != nil {
+		return fmt.Errorf("failed to pack objects: %w", err)
+	}
+	if stats.Objects == 0 {
+		fmt.Println("Nothing to pack")
+		return nil
+	}
+
+	fmt.Printf("Packed %d objects (%d as deltas): %s loose -> %s packed\n",
+		stats.Objects, stats.Deltas, utils.FormatSize(stats.LooseBytes), utils.FormatSize(stats.PackedBytes))
+	return nil
+}
+
+// usePacks moves the story to the packed format before a pack is written,
+// so builds that only know loose objects refuse it instead of missing
+// whatever moved into the pack.
+func (app *App) usePacks() error {
+	return app.raiseFormatVersion(storage.PackedFormatVersion)
+}
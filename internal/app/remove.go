@@ -0,0 +1,75 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// RemoveFiles prepares the deletion of tracked files and, unless keep is
+// set, deletes them. Files with unprepared changes are only deleted with
+// force.
+func (app *App) RemoveFiles(paths []string, keep, force bool) error {
+	unlock, err := app.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	head, err := app.headSnapshot()
+	if err != nil {
+		return err
+	}
+	prepared, err := app.index.GetPreparedFiles()
+	if err != nil {
+		return fmt.Errorf("failed to get prepared files: %w", err)
+	}
+	modes, err := app.index.GetPreparedModes()
+	if err != nil {
+		return fmt.Errorf("failed to get prepared modes: %w", err)
+	}
+
+	known := make(map[string]string)
+	for path, hash := range head.Files {
+		known[path] = hash
+	}
+	for path, hash := range prepared {
+		if hash != "" {
+			known[path] = hash
+		}
+	}
+
+	var targets []string
+	for _, path := range paths {
+		tracked := trackedUnder(known, path)
+		if len(tracked) == 0 {
+			return fmt.Errorf("path is not tracked: %s", path)
+		}
+		targets = append(targets, tracked...)
+	}
+
+	if !keep && !force {
+		dirty, err := app.unpreparedChanges(targets, nil)
+		if err != nil {
+			return err
+		}
+		if len(dirty) > 0 {
+			return fmt.Errorf("removing would discard unprepared changes in:\n  %s\nprepare them first, or use --keep or --force", strings.Join(dirty, "\n  "))
+		}
+	}
+
+	for _, path := range targets {
+		if !keep {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to remove %s: %w", path, err)
+			}
+			removeEmptyParents(path)
+		}
+		prepareDeletion(path, head, prepared, modes)
+	}
+
+	if err := app.index.PrepareModes(modes); err != nil {
+		return fmt.Errorf("failed to save prepared modes: %w", err)
+	}
+	return app.index.PrepareFiles(prepared)
+}
@@ -0,0 +1,102 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const StoryDirName = ".nora"
+
+// Location ties a story directory to the working tree it tracks: the
+// directory that contains it. Dir is where the command was started, so
+// paths given on the command line can be turned into tree paths.
+type Location struct {
+	StoryDir string
+	WorkTree string
+	Dir      string
+}
+
+// Locate finds the story for a command started in dir. An override, from
+// --story-dir or NORA_DIR, is used as is; otherwise init creates a story
+// in dir and every other command looks for one in dir and its parents.
+func Locate(dir, override string, create bool) (*Location, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var storyDir string
+	switch {
+	case override != "":
+		if !filepath.IsAbs(override) {
+			override = filepath.Join(dir, override)
+		}
+		storyDir = filepath.Clean(override)
+		if !create {
+			if info, err := os.Stat(storyDir); err != nil || !info.IsDir() {
+				return nil, fmt.Errorf("not a nora story: %s", storyDir)
+			}
+		}
+	case create:
+		storyDir = filepath.Join(dir, StoryDirName)
+	default:
+		if storyDir, err = FindStoryDir(dir); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Location{StoryDir: storyDir, WorkTree: filepath.Dir(storyDir), Dir: dir}, nil
+}
+
+// FindStoryDir walks up from dir to the closest directory holding a story.
+func FindStoryDir(dir string) (string, error) {
+	for current := dir; ; {
+		candidate := filepath.Join(current, StoryDirName)
+		if info, err := os.Stat(candidate); err == nil && info.IsDir() {
+			return candidate, nil
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			return "", fmt.Errorf("not a nora story (or any of the parent directories): %s", StoryDirName)
+		}
+		current = parent
+	}
+}
+
+// RepoPath turns a path given relative to the starting directory into a
+// slash-separated path relative to the working tree root.
+func (loc *Location) RepoPath(path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(loc.Dir, path)
+	}
+	rel, err := filepath.Rel(loc.WorkTree, path)
+	if err != nil {
+		return "", err
+	}
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside the working tree at %s", path, loc.WorkTree)
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+func (loc *Location) RepoPaths(paths []string) ([]string, error) {
+	mapped := make([]string, len(paths))
+	for i, path := range paths {
+		var err error
+		if mapped[i], err = loc.RepoPath(path); err != nil {
+			return nil, err
+		}
+	}
+	return mapped, nil
+}
+
+// Enter changes into the working tree root and returns the story
+// directory relative to it, so every later path is tree-relative.
+func (loc *Location) Enter() (string, error) {
+	if err := os.Chdir(loc.WorkTree); err != nil {
+		return "", err
+	}
+	return filepath.Rel(loc.WorkTree, loc.StoryDir)
+}
@@ -0,0 +1,190 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jolovicdev/nora/internal/core/storage"
+	"github.com/jolovicdev/nora/internal/types"
+)
+
+func (app *App) RestoreSnapshot(rev string, paths []string, force bool) error {
+	unlock, err := app.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	id, err := app.ResolveRevision(rev)
+	if err != nil {
+		return err
+	}
+	snap, err := app.snapshots.Get(id)
+	if err != nil {
+		return fmt.Errorf("failed to get snapshot: %w", err)
+	}
+
+	files, err := selectPaths(snap.Files, paths)
+	if err != nil {
+		return err
+	}
+
+	if !force {
+		dirty, err := app.unpreparedChanges(files, snap.Files)
+		if err != nil {
+			return err
+		}
+		if len(dirty) > 0 {
+			return fmt.Errorf("restoring would overwrite unprepared changes in:\n  %s\nprepare or capture them first, or use --force", strings.Join(dirty, "\n  "))
+		}
+	}
+
+	return app.restoreFiles(snap, files)
+}
+
+func (app *App) restoreFiles(snap *types.Snapshot, files []string) error {
+	for _, path := range files {
+		content, err := app.contentStore.Get(snap.Files[path])
+		if err != nil {
+			return fmt.Errorf("failed to read content for %s: %w", path, err)
+		}
+		if err := writeWorkingFile(path, content, snap.Modes[path]); err != nil {
+			return err
+		}
+		fmt.Printf("Restored: %s\n", path)
+	}
+	return nil
+}
+
+func (app *App) unpreparedChanges(files []string, target map[string]string) ([]string, error) {
+	prepared, err := app.index.GetPreparedFiles()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get prepared files: %w", err)
+	}
+
+	timeline, err := app.timelines.GetCurrent()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current timeline: %w", err)
+	}
+
+	headFiles := make(map[string]string)
+	if timeline.Current != "" {
+		head, err := app.snapshots.Get(timeline.Current)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get current snapshot: %w", err)
+		}
+		headFiles = head.Files
+	}
+
+	var dirty []string
+	for _, path := range files {
+		if _, err := os.Lstat(path); os.IsNotExist(err) {
+			continue
+		}
+		content, _, err := readWorkingFile(path)
+		if err != nil {
+			return nil, err
+		}
+		hash := storage.Hash(content)
+		if hash == target[path] {
+			continue
+		}
+
+		known, ok := prepared[path]
+		if !ok {
+			known, ok = headFiles[path]
+		}
+		if !ok || hash != known {
+			dirty = append(dirty, path)
+		}
+	}
+	return dirty, nil
+}
+
+func selectPaths(files map[string]string, paths []string) ([]string, error) {
+	var selected []string
+	matched := make(map[string]bool)
+	for path := range files {
+		if len(paths) == 0 {
+			selected = append(selected, path)
+			continue
+		}
+		for _, p := range paths {
+			if pathMatches(path, p) {
+				selected = append(selected, path)
+				matched[p] = true
+				break
+			}
+		}
+	}
+
+	for _, p := range paths {
+		if !matched[p] {
+			return nil, fmt.Errorf("path not found in snapshot: %s", p)
+		}
+	}
+
+	sort.Strings(selected)
+	return selected, nil
+}
+
+func trackedUnder(files map[string]string, pattern string) []string {
+	var tracked []string
+	for path := range files {
+		if pathMatches(path, pattern) {
+			tracked = append(tracked, path)
+		}
+	}
+	sort.Strings(tracked)
+	return tracked
+}
+
+func sortedPaths(files map[string]string) []string {
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func pathMatches(path, pattern string) bool {
+	pattern = strings.TrimSuffix(filepath.Clean(pattern), "/")
+	if pattern == "." {
+		return true
+	}
+	return path == pattern || strings.HasPrefix(path, pattern+"/")
+}
+
+func writeWorkingFile(path string, content []byte, mode os.FileMode) error {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create directory %s: %w", dir, err)
+		}
+	}
+
+	if info, err := os.Lstat(path); err == nil && (mode&os.ModeSymlink != 0 || info.Mode()&os.ModeSymlink != 0) {
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+	}
+
+	if mode&os.ModeSymlink != 0 {
+		if err := os.Symlink(string(content), path); err != nil {
+			return fmt.Errorf("failed to create symlink %s: %w", path, err)
+		}
+		return nil
+	}
+
+	perm := mode.Perm()
+	if perm == 0 {
+		perm = 0644
+	}
+	if err := os.WriteFile(path, content, perm); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return os.Chmod(path, perm)
+}
@@ -0,0 +1,115 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strings"
+	"time"
+
+	"github.com/jolovicdev/nora/internal/core/config"
+	"github.com/jolovicdev/nora/internal/core/snapshot"
+	"github.com/jolovicdev/nora/internal/types"
+	"github.com/jolovicdev/nora/internal/utils"
+)
+
+// CaptureOptions override the author of a new snapshot, mainly for
+// importing history made elsewhere.
+type CaptureOptions struct {
+	Author string
+	Date   time.Time
+}
+
+// signatures works out the author and committer of a new snapshot. Names
+// and emails come from NORA_AUTHOR_* and NORA_COMMITTER_* variables, then
+// the user.name and user.email settings, then the login name and host;
+// NORA_AUTHOR_DATE and NORA_COMMITTER_DATE set the times.
+func (app *App) signatures(opts CaptureOptions) (*types.Signature, *types.Signature, error) {
+	settings, err := app.loadSettings()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	committed, err := envDate("NORA_COMMITTER_DATE", now)
+	if err != nil {
+		return nil, nil, err
+	}
+	authored, err := envDate("NORA_AUTHOR_DATE", committed)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !opts.Date.IsZero() {
+		authored = opts.Date
+	}
+
+	name, email := identity("NORA_AUTHOR", settings)
+	if opts.Author != "" {
+		if name, email, err = snapshot.ParseIdentity(opts.Author); err != nil {
+			return nil, nil, err
+		}
+	}
+	author := snapshot.NewSignature(name, email, authored)
+
+	name, email = identity("NORA_COMMITTER", settings)
+	committer := snapshot.NewSignature(name, email, committed)
+	return author, committer, nil
+}
+
+func identity(prefix string, settings *config.Config) (string, string) {
+	name := firstSet(os.Getenv(prefix+"_NAME"), settings.Get("user.name"))
+	email := firstSet(os.Getenv(prefix+"_EMAIL"), settings.Get("user.email"))
+	if name != "" && email != "" {
+		return name, email
+	}
+
+	login := "unknown"
+	if current, err := user.Current(); err == nil && current.Username != "" {
+		login = current.Username
+	}
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "localhost"
+	}
+	return firstSet(name, login), firstSet(email, login+"@"+host)
+}
+
+func envDate(name string, fallback time.Time) (time.Time, error) {
+	value := strings.TrimSpace(os.Getenv(name))
+	if value == "" {
+		return fallback, nil
+	}
+	when, err := utils.ParseDate(value, time.Now())
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s: %w", name, err)
+	}
+	return when, nil
+}
+
+func firstSet(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+const dateFormat = "Mon Jan 2 15:04:05 2006 -0700"
+
+// printSignatures shows who made a snapshot, naming the committer only
+// when it differs from the author.
+func printSignatures(snap *types.Snapshot) {
+	if snap.Author == nil {
+		return
+	}
+	fmt.Printf("Author: %s\n", snapshot.FormatIdentity(snap.Author))
+	fmt.Printf("Date:   %s\n", snapshot.SignatureTime(snap.Author).Format(dateFormat))
+
+	committer := snap.Committer
+	if committer == nil || *committer == *snap.Author {
+		return
+	}
+	fmt.Printf("Committer: %s\n", snapshot.FormatIdentity(committer))
+	fmt.Printf("CommitDate: %s\n", snapshot.SignatureTime(committer).Format(dateFormat))
+}
@@ -0,0 +1,57 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestStatusPersistsStatCache(t *testing.T) {
+	story := newTestStory(t)
+
+	// Files must be older than the racy window to be cached.
+	old := time.Now().Add(-time.Hour)
+	for i := 0; i < 5; i++ {
+		name := fmt.Sprintf("file%d.txt", i)
+		if err := os.WriteFile(name, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(name, old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := story.GetStatus(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A new App stands in for the next nora process.
+	next := New(StoryDirName)
+	cache, err := next.index.GetStatCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cache) != 5 {
+		t.Fatalf("status saved %d stat cache entries, want 5", len(cache))
+	}
+
+	matcher, err := next.ignoreMatcher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashed := 0
+	skip := walkFilter(StoryDirName, matcher)
+	err = next.walkWorkingTree(".", skip, cache, nil, func(file *workingFile) error {
+		if file.fresh {
+			hashed++
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hashed != 0 {
+		t.Errorf("second status re-hashed %d unchanged files, want 0", hashed)
+	}
+}
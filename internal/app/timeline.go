@@ -0,0 +1,272 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jolovicdev/nora/internal/types"
+)
+
+func (app *App) CreateTimeline(name string, switchTo bool) error {
+	unlock, err := app.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	current, err := app.timelines.GetCurrent()
+	if err != nil {
+		return fmt.Errorf("failed to get current timeline: %w", err)
+	}
+
+	if err := app.timelines.Fork(name, current); err != nil {
+		return fmt.Errorf("failed to create timeline: %w", err)
+	}
+	fmt.Printf("Created timeline %s at %s\n", name, shortID(current.Current))
+
+	if switchTo {
+		return app.timelines.Switch(name)
+	}
+	return nil
+}
+
+func (app *App) SwitchTimeline(name string, force bool) error {
+	unlock, err := app.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	current, err := app.timelines.GetCurrent()
+	if err != nil {
+		return fmt.Errorf("failed to get current timeline: %w", err)
+	}
+	if current.Name == name {
+		fmt.Printf("Already on timeline %s\n", name)
+		return nil
+	}
+
+	target, err := app.timelines.Get(name)
+	if err != nil {
+		return err
+	}
+
+	prepared, err := app.index.GetPreparedFiles()
+	if err != nil {
+		return fmt.Errorf("failed to get prepared files: %w", err)
+	}
+	if len(prepared) > 0 && !force {
+		return fmt.Errorf("you have prepared changes; capture or forget them first, or use --force to discard them")
+	}
+
+	from, err := app.snapshotOrEmpty(current.Current)
+	if err != nil {
+		return err
+	}
+	to, err := app.snapshotOrEmpty(target.Current)
+	if err != nil {
+		return err
+	}
+
+	if err := app.checkout(from, to, force); err != nil {
+		return err
+	}
+
+	if len(prepared) > 0 {
+		if err := app.index.PrepareFiles(make(map[string]string)); err != nil {
+			return fmt.Errorf("failed to clear prepared files: %w", err)
+		}
+		if err := app.index.PrepareModes(make(map[string]os.FileMode)); err != nil {
+			return fmt.Errorf("failed to clear prepared modes: %w", err)
+		}
+	}
+
+	if err := app.timelines.Switch(name); err != nil {
+		return fmt.Errorf("failed to switch timeline: %w", err)
+	}
+	fmt.Printf("Switched to timeline %s\n", name)
+	return nil
+}
+
+func (app *App) checkout(from, to *types.Snapshot, force bool) error {
+	var restore, remove []string
+	for path, hash := range to.Files {
+		if from.Files[path] != hash {
+			restore = append(restore, path)
+		}
+	}
+	for path := range from.Files {
+		if _, ok := to.Files[path]; !ok {
+			remove = append(remove, path)
+		}
+	}
+	sort.Strings(restore)
+	sort.Strings(remove)
+
+	if !force {
+		dirty, err := app.unpreparedChanges(append(append([]string{}, restore...), remove...), to.Files)
+		if err != nil {
+			return err
+		}
+		if len(dirty) > 0 {
+			return fmt.Errorf("switching would overwrite unprepared changes in:\n  %s\nprepare or capture them first, or use --force", strings.Join(dirty, "\n  "))
+		}
+	}
+
+	if err := app.restoreFiles(to, restore); err != nil {
+		return err
+	}
+	for _, path := range remove {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+		removeEmptyParents(path)
+	}
+	return nil
+}
+
+func (app *App) ListTimelines() error {
+	timelines, err := app.timelines.List()
+	if err != nil {
+		return fmt.Errorf("failed to list timelines: %w", err)
+	}
+	currentName, err := app.timelines.CurrentName()
+	if err != nil {
+		return err
+	}
+
+	for _, timeline := range timelines {
+		marker := "  "
+		color := Reset
+		if timeline.Name == currentName {
+			marker = "* "
+			color = Green
+		}
+
+		head := "(no snapshots)"
+		if timeline.Current != "" {
+			head = shortID(timeline.Current)
+			if snap, err := app.snapshots.Get(timeline.Current); err == nil {
+				head += " " + firstLine(snap.Message)
+			}
+		}
+		fmt.Printf("%s%s%s%s %s\n", marker, color, timeline.Name, Reset, head)
+	}
+	return nil
+}
+
+func (app *App) DeleteTimeline(name string, force bool) error {
+	unlock, err := app.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	target, err := app.timelines.Get(name)
+	if err != nil {
+		return err
+	}
+
+	if target.Current != "" && !force {
+		reachable, err := app.reachableFromOthers(name)
+		if err != nil {
+			return err
+		}
+		if !reachable[target.Current] {
+			return fmt.Errorf("timeline %s has snapshots that are not on any other timeline; use --force to delete it anyway", name)
+		}
+	}
+
+	if err := app.timelines.Delete(name); err != nil {
+		return err
+	}
+	fmt.Printf("Deleted timeline %s (was %s)\n", name, shortID(target.Current))
+	return nil
+}
+
+func (app *App) RenameTimeline(oldName, newName string) error {
+	unlock, err := app.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := app.timelines.Rename(oldName, newName); err != nil {
+		return err
+	}
+	fmt.Printf("Renamed timeline %s to %s\n", oldName, newName)
+	return nil
+}
+
+func (app *App) reachableFromOthers(name string) (map[string]bool, error) {
+	timelines, err := app.timelines.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list timelines: %w", err)
+	}
+
+	reachable := make(map[string]bool)
+	for _, timeline := range timelines {
+		if timeline.Name == name {
+			continue
+		}
+		for id := timeline.Current; id != "" && !reachable[id]; {
+			reachable[id] = true
+			snap, err := app.snapshots.Get(id)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get snapshot %s: %w", id, err)
+			}
+			id = snap.Parent
+		}
+	}
+	return reachable, nil
+}
+
+func (app *App) headSnapshot() (*types.Snapshot, error) {
+	timeline, err := app.timelines.GetCurrent()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current timeline: %w", err)
+	}
+	return app.snapshotOrEmpty(timeline.Current)
+}
+
+func (app *App) snapshotOrEmpty(id string) (*types.Snapshot, error) {
+	if id == "" {
+		return &types.Snapshot{Files: make(map[string]string)}, nil
+	}
+	snap, err := app.snapshots.Get(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get snapshot %s: %w", id, err)
+	}
+	if snap.Files == nil {
+		snap.Files = make(map[string]string)
+	}
+	return snap, nil
+}
+
+func removeEmptyParents(path string) {
+	for dir := filepath.Dir(path); dir != "." && dir != string(filepath.Separator); dir = filepath.Dir(dir) {
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+	}
+}
+
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	if id == "" {
+		return "(empty)"
+	}
+	return id
+}
+
+func firstLine(message string) string {
+	if i := strings.IndexByte(message, '\n'); i >= 0 {
+		return message[:i]
+	}
+	return message
+}
@@ -0,0 +1,223 @@
+package app
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sync"
+
+	"github.com/jolovicdev/nora/internal/core/ignore"
+	"github.com/jolovicdev/nora/internal/core/storage"
+)
+
+// workingFile is one regular file or symlink found by walkWorkingTree.
+// visit callbacks run on the worker pool and may set state and binary for
+// the collector; content is only held while visit runs.
+type workingFile struct {
+	seq     int
+	path    string
+	info    os.FileInfo
+	mode    os.FileMode
+	hash    string
+	content []byte
+	fresh   bool
+	state   string
+	binary  bool
+	err     error
+}
+
+func (app *App) SetJobs(jobs int) {
+	app.jobs = jobs
+}
+
+func (app *App) workers() int {
+	if app.jobs > 0 {
+		return app.jobs
+	}
+	return runtime.NumCPU()
+}
+
+// walkWorkingTree walks root on one goroutine, hashes files on a bounded
+// pool of workers and hands the results to collect in walk order on the
+// calling goroutine. Files whose stat data matches the cache are not read;
+// the cache is refreshed with every file that was hashed.
+func (app *App) walkWorkingTree(root string, skip func(path string, isDir bool) (bool, error), cache storage.StatCache, visit, collect func(*workingFile) error) error {
+	jobs := app.workers()
+	done := make(chan struct{})
+	defer close(done)
+
+	type task struct {
+		seq   int
+		path  string
+		entry fs.DirEntry
+	}
+	tasks := make(chan task, jobs*4)
+	results := make(chan *workingFile, jobs*4)
+	walkErr := make(chan error, 1)
+
+	go func() {
+		defer close(tasks)
+		seq := 0
+		walkErr <- filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if path != root {
+				skipped, err := skip(path, entry.IsDir())
+				if err != nil {
+					return err
+				}
+				if skipped && entry.IsDir() {
+					return filepath.SkipDir
+				}
+				if skipped {
+					return nil
+				}
+			}
+			if entry.IsDir() {
+				return nil
+			}
+			select {
+			case tasks <- task{seq: seq, path: path, entry: entry}:
+				seq++
+				return nil
+			case <-done:
+				return filepath.SkipAll
+			}
+		})
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range tasks {
+				file := &workingFile{seq: t.seq, path: t.path}
+				file.err = hashFile(file, t.entry, cache, visit)
+				file.content = nil
+				select {
+				case results <- file:
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// Workers read the cache concurrently, so fresh hashes are only
+	// recorded once every worker has finished.
+	var fresh []*workingFile
+	pending := make(map[int]*workingFile)
+	next := 0
+	for file := range results {
+		pending[file.seq] = file
+		for {
+			ready, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+
+			if ready.err != nil {
+				return fmt.Errorf("failed to hash %s: %w", ready.path, ready.err)
+			}
+			if ready.fresh {
+				fresh = append(fresh, ready)
+			}
+			if err := collect(ready); err != nil {
+				return err
+			}
+		}
+	}
+	if err := <-walkErr; err != nil {
+		return err
+	}
+
+	for _, file := range fresh {
+		cache.Record(file.path, file.info, file.hash)
+	}
+	return nil
+}
+
+// walkFilter skips the story directory and ignored paths. Tracked files
+// are never ignored, so they and the directories leading to them are
+// always walked.
+func walkFilter(storyDir string, matcher *ignore.Matcher, tracked ...map[string]string) func(string, bool) (bool, error) {
+	storyDir = filepath.ToSlash(filepath.Clean(storyDir))
+	keep := trackedPaths(tracked...)
+
+	return func(file string, isDir bool) (bool, error) {
+		file = filepath.ToSlash(filepath.Clean(file))
+		if file == storyDir {
+			return true, nil
+		}
+		if keep[file] {
+			return false, nil
+		}
+		return matcher.Ignored(file, isDir)
+	}
+}
+
+// trackedPaths returns the tracked files and every directory leading to
+// them.
+func trackedPaths(tracked ...map[string]string) map[string]bool {
+	keep := make(map[string]bool)
+	for _, files := range tracked {
+		for file := range files {
+			for dir := file; dir != "." && !keep[dir]; dir = path.Dir(dir) {
+				keep[dir] = true
+			}
+		}
+	}
+	return keep
+}
+
+func hashFile(file *workingFile, entry fs.DirEntry, cache storage.StatCache, visit func(*workingFile) error) error {
+	info, err := entry.Info()
+	if err != nil {
+		return err
+	}
+	file.info = info
+	file.mode = info.Mode().Perm()
+	if info.Mode()&os.ModeSymlink != 0 {
+		file.mode = os.ModeSymlink
+	}
+
+	if hash, ok := cache.Lookup(file.path, info); ok {
+		file.hash = hash
+	} else {
+		if file.content, _, err = readWorkingFile(file.path); err != nil {
+			return err
+		}
+		file.hash = storage.Hash(file.content)
+		file.fresh = true
+	}
+
+	if visit != nil {
+		return visit(file)
+	}
+	return nil
+}
+
+// load returns the file's content, reading it if the hash came from the
+// stat cache.
+func (file *workingFile) load() ([]byte, error) {
+	if file.content != nil {
+		return file.content, nil
+	}
+	content, _, err := readWorkingFile(file.path)
+	if err != nil {
+		return nil, err
+	}
+	file.content = content
+	return content, nil
+}
@@ -0,0 +1,170 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Scope is a configuration layer. Values in later scopes override those
+// in earlier ones.
+type Scope int
+
+const (
+	System Scope = iota
+	User
+	Repo
+	Env
+)
+
+func (s Scope) String() string {
+	switch s {
+	case System:
+		return "system"
+	case User:
+		return "user"
+	case Repo:
+		return "repo"
+	default:
+		return "env"
+	}
+}
+
+// Source is a configuration file and the scope it belongs to.
+type Source struct {
+	Scope Scope
+	Path  string
+}
+
+// Sources lists the files a story reads its configuration from, lowest
+// precedence first. NORA_CONFIG_SYSTEM and NORA_CONFIG_USER replace the
+// system and user paths. An empty repoPath, outside any story, leaves out
+// the repo file.
+func Sources(repoPath string) []Source {
+	system := os.Getenv("NORA_CONFIG_SYSTEM")
+	if system == "" {
+		system = "/etc/nora/config"
+	}
+
+	user := os.Getenv("NORA_CONFIG_USER")
+	if user == "" {
+		if dir, err := os.UserConfigDir(); err == nil {
+			user = filepath.Join(dir, "nora", "config")
+		}
+	}
+
+	sources := []Source{{Scope: System, Path: system}}
+	if user != "" {
+		sources = append(sources, Source{Scope: User, Path: user})
+	}
+	if repoPath != "" {
+		sources = append(sources, Source{Scope: Repo, Path: repoPath})
+	}
+	return sources
+}
+
+// Entry is the effective value of a key and where it came from: a file
+// path, or the environment variable that set it.
+type Entry struct {
+	Key    string
+	Value  string
+	Scope  Scope
+	Origin string
+}
+
+// Config is the merged view of every source plus environment overrides.
+type Config struct {
+	entries map[string]Entry
+}
+
+// Load reads sources in order, skipping files that do not exist, then
+// applies the environment variables of the known keys.
+func Load(sources []Source) (*Config, error) {
+	c := &Config{entries: make(map[string]Entry)}
+	for _, source := range sources {
+		file, err := ReadFile(source.Path)
+		if err != nil {
+			return nil, err
+		}
+		for _, value := range file.values() {
+			c.entries[value.key] = Entry{Key: value.key, Value: value.value, Scope: source.Scope, Origin: source.Path}
+		}
+	}
+
+	for _, key := range Keys {
+		if value, ok := os.LookupEnv(key.Env); ok && key.Env != "" {
+			if err := key.Validate(value); err != nil {
+				return nil, fmt.Errorf("%s: %w", key.Env, err)
+			}
+			c.entries[key.Name] = Entry{Key: key.Name, Value: value, Scope: Env, Origin: key.Env}
+		}
+	}
+	return c, nil
+}
+
+func (c *Config) Lookup(key string) (Entry, bool) {
+	entry, ok := c.entries[key]
+	return entry, ok
+}
+
+// Get returns the value of key, or "" when it is not set.
+func (c *Config) Get(key string) string {
+	return c.entries[key].Value
+}
+
+// List returns the effective value of every key that is set, sorted.
+func (c *Config) List() []Entry {
+	entries := make([]Entry, 0, len(c.entries))
+	for _, entry := range c.entries {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Key < entries[j].Key
+	})
+	return entries
+}
+
+// Set writes key to the file at path, creating it if needed.
+func Set(path, key, value string) error {
+	k, err := LookupKey(key)
+	if err != nil {
+		return err
+	}
+	if err := k.Validate(value); err != nil {
+		return err
+	}
+
+	file, err := ReadFile(path)
+	if err != nil {
+		return err
+	}
+	file.set(key, value)
+	return file.Write()
+}
+
+// Unset removes key from the file at path and reports whether it was
+// there.
+func Unset(path, key string) (bool, error) {
+	if _, err := LookupKey(key); err != nil {
+		return false, err
+	}
+
+	file, err := ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	if !file.unset(key) {
+		return false, nil
+	}
+	return true, file.Write()
+}
+
+func splitKey(key string) (string, string, error) {
+	dot := strings.IndexByte(key, '.')
+	if dot <= 0 || dot == len(key)-1 {
+		return "", "", fmt.Errorf("invalid config key %q, expected section.name", key)
+	}
+	return key[:dot], key[dot+1:], nil
+}
@@ -0,0 +1,266 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func readBack(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestReadFile(t *testing.T) {
+	path := writeConfig(t, `# leading comment
+; another comment
+
+[User]
+	name = Ada Lovelace   # trailing comment
+	email=ada@example.com
+[alias]
+	st = status
+	lg = "history --since \"1 week\" ; # kept"
+[color]
+	ui = never
+	ui = always
+`)
+	file, err := ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"user.name":  "Ada Lovelace",
+		"user.email": "ada@example.com",
+		"alias.st":   "status",
+		"alias.lg":   `history --since "1 week" ; # kept`,
+		"color.ui":   "always",
+	}
+	for key, value := range want {
+		if got, ok := file.Get(key); !ok || got != value {
+			t.Errorf("%s = %q, %v; want %q", key, got, ok, value)
+		}
+	}
+	if _, ok := file.Get("core.pager"); ok {
+		t.Error("core.pager is set in a file that does not set it")
+	}
+}
+
+func TestReadFileMissing(t *testing.T) {
+	file, err := ReadFile(filepath.Join(t.TempDir(), "missing"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(file.values()) != 0 {
+		t.Errorf("missing file has values: %v", file.values())
+	}
+}
+
+func TestReadFileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"unclosed section", "[user\n\tname = a\n"},
+		{"setting outside a section", "name = a\n"},
+		{"missing key name", "[user]\n\t= a\n"},
+		{"unterminated quote", "[user]\n\tname = \"a\n"},
+		{"invalid value", "[color]\n\tui = sometimes\n"},
+		{"invalid algorithm", "[diff]\n\talgorithm = fastest\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ReadFile(writeConfig(t, tt.content)); err == nil {
+				t.Error("read without error")
+			}
+		})
+	}
+}
+
+func TestSetKeepsOtherLines(t *testing.T) {
+	original := `# my settings
+[user]
+	name = Ada   ; old name
+[alias]
+	st = status
+`
+	tests := []struct {
+		name  string
+		key   string
+		value string
+		want  string
+	}{
+		{"replace in place", "user.name", "Ada Lovelace", `# my settings
+[user]
+	name = Ada Lovelace
+[alias]
+	st = status
+`},
+		{"append to a section", "user.email", "ada@example.com", `# my settings
+[user]
+	name = Ada   ; old name
+	email = ada@example.com
+[alias]
+	st = status
+`},
+		{"new section", "color.ui", "never", original + `[color]
+	ui = never
+`},
+		{"quote when needed", "alias.x", " has #hash", `# my settings
+[user]
+	name = Ada   ; old name
+[alias]
+	st = status
+	x = " has #hash"
+`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeConfig(t, original)
+			if err := Set(path, tt.key, tt.value); err != nil {
+				t.Fatal(err)
+			}
+			if got := readBack(t, path); got != tt.want {
+				t.Errorf("file is now\n%s\nwant\n%s", got, tt.want)
+			}
+			file, err := ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, _ := file.Get(tt.key); got != tt.value {
+				t.Errorf("%s reads back as %q, want %q", tt.key, got, tt.value)
+			}
+		})
+	}
+}
+
+func TestSetRejectsBadKeysAndValues(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	for _, tt := range [][2]string{
+		{"nosection", "x"},
+		{"unknown.key", "x"},
+		{"color.ui", "sometimes"},
+		{"alias.two words", "status"},
+	} {
+		if err := Set(path, tt[0], tt[1]); err == nil {
+			t.Errorf("Set(%q, %q) succeeded", tt[0], tt[1])
+		}
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("a rejected Set created the file")
+	}
+}
+
+func TestUnset(t *testing.T) {
+	path := writeConfig(t, `# my settings
+[user]
+	name = Ada
+	name = Ada again
+[alias]
+	st = status
+`)
+	removed, err := Unset(path, "user.name")
+	if err != nil || !removed {
+		t.Fatalf("Unset = %v, %v", removed, err)
+	}
+	want := `# my settings
+[alias]
+	st = status
+`
+	if got := readBack(t, path); got != want {
+		t.Errorf("file is now\n%s\nwant\n%s", got, want)
+	}
+
+	removed, err = Unset(path, "user.email")
+	if err != nil || removed {
+		t.Errorf("Unset of a missing key = %v, %v", removed, err)
+	}
+	if got := readBack(t, path); got != want {
+		t.Errorf("unsetting a missing key changed the file:\n%s", got)
+	}
+}
+
+func TestLoadLayers(t *testing.T) {
+	system := writeConfig(t, "[user]\n\tname = System\n\temail = system@example.com\n[color]\n\tui = never\n")
+	user := writeConfig(t, "[user]\n\tname = User\n[core]\n\tpager = more\n")
+	repo := writeConfig(t, "[user]\n\tname = Repo\n")
+	for _, key := range Keys {
+		// Setenv first so the variable is restored after the test.
+		t.Setenv(key.Env, "")
+		os.Unsetenv(key.Env)
+	}
+	t.Setenv("NORA_PAGER", "cat")
+
+	c, err := Load([]Source{
+		{Scope: System, Path: system},
+		{Scope: User, Path: user},
+		{Scope: Repo, Path: repo},
+		{Scope: Repo, Path: filepath.Join(t.TempDir(), "missing")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		key    string
+		value  string
+		scope  Scope
+		origin string
+	}{
+		{"user.name", "Repo", Repo, repo},
+		{"user.email", "system@example.com", System, system},
+		{"color.ui", "never", System, system},
+		{"core.pager", "cat", Env, "NORA_PAGER"},
+	}
+	for _, tt := range tests {
+		entry, ok := c.Lookup(tt.key)
+		if !ok || entry.Value != tt.value || entry.Scope != tt.scope || entry.Origin != tt.origin {
+			t.Errorf("%s = %+v, want %q from %s %s", tt.key, entry, tt.value, tt.scope, tt.origin)
+		}
+	}
+	if got := c.Get("diff.algorithm"); got != "" {
+		t.Errorf("unset key reads as %q", got)
+	}
+	if list := c.List(); len(list) != 4 || list[0].Key != "color.ui" {
+		t.Errorf("List = %v", list)
+	}
+}
+
+func TestLoadRejectsInvalidEnvironment(t *testing.T) {
+	t.Setenv("NORA_COLOR", "sometimes")
+	if _, err := Load(nil); err == nil {
+		t.Error("loaded an invalid NORA_COLOR without error")
+	}
+}
+
+func TestSources(t *testing.T) {
+	t.Setenv("NORA_CONFIG_SYSTEM", "/custom/system")
+	t.Setenv("NORA_CONFIG_USER", "/custom/user")
+
+	sources := Sources(".nora/config")
+	want := []Source{{System, "/custom/system"}, {User, "/custom/user"}, {Repo, ".nora/config"}}
+	if len(sources) != len(want) {
+		t.Fatalf("Sources = %v, want %v", sources, want)
+	}
+	for i := range want {
+		if sources[i] != want[i] {
+			t.Errorf("source %d = %v, want %v", i, sources[i], want[i])
+		}
+	}
+
+	if sources := Sources(""); len(sources) != 2 || sources[1].Scope != User {
+		t.Errorf("Sources outside a story = %v", sources)
+	}
+}
@@ -0,0 +1,232 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/jolovicdev/nora/internal/utils"
+)
+
+// File is a configuration file in the .gitconfig style:
+//
+//	[user]
+//		name = Ada Lovelace
+//	[alias]
+//		st = status
+//
+// Lines are kept as read, so setting a key leaves comments and the order
+// of everything else alone.
+type File struct {
+	Path  string
+	lines []line
+}
+
+type line struct {
+	text    string
+	section string
+	key     string
+	value   string
+}
+
+type fileValue struct {
+	key   string
+	value string
+}
+
+// ReadFile parses the file at path. A missing file reads as empty.
+func ReadFile(path string) (*File, error) {
+	file := &File{Path: path}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return file, nil
+		}
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	section := ""
+	text := strings.TrimSuffix(string(data), "\n")
+	if text == "" {
+		return file, nil
+	}
+	for i, raw := range strings.Split(text, "\n") {
+		parsed := line{text: raw}
+		trimmed := strings.TrimSpace(strings.TrimRight(raw, "\r"))
+		switch {
+		case trimmed == "" || trimmed[0] == '#' || trimmed[0] == ';':
+		case trimmed[0] == '[':
+			if !strings.HasSuffix(trimmed, "]") {
+				return nil, fmt.Errorf("%s:%d: invalid section header", path, i+1)
+			}
+			section = strings.ToLower(strings.TrimSpace(trimmed[1 : len(trimmed)-1]))
+			parsed.section = section
+		default:
+			if section == "" {
+				return nil, fmt.Errorf("%s:%d: setting outside a section", path, i+1)
+			}
+			name, value, _ := strings.Cut(trimmed, "=")
+			name = strings.TrimSpace(name)
+			if name == "" {
+				return nil, fmt.Errorf("%s:%d: missing key name", path, i+1)
+			}
+			if value, err = parseValue(value); err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", path, i+1, err)
+			}
+			parsed.section = section
+			parsed.key = section + "." + name
+			parsed.value = value
+		}
+		file.lines = append(file.lines, parsed)
+	}
+
+	for _, value := range file.values() {
+		key, err := LookupKey(value.key)
+		if err != nil {
+			continue
+		}
+		if err := key.Validate(value.value); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return file, nil
+}
+
+// Get returns the value of key in this file alone.
+func (f *File) Get(key string) (string, bool) {
+	for _, value := range f.values() {
+		if value.key == key {
+			return value.value, true
+		}
+	}
+	return "", false
+}
+
+// values returns every setting in the file; a key set twice keeps the
+// last value.
+func (f *File) values() []fileValue {
+	var values []fileValue
+	index := make(map[string]int)
+	for _, line := range f.lines {
+		if line.key == "" {
+			continue
+		}
+		if i, ok := index[line.key]; ok {
+			values[i].value = line.value
+			continue
+		}
+		index[line.key] = len(values)
+		values = append(values, fileValue{key: line.key, value: line.value})
+	}
+	return values
+}
+
+func (f *File) set(key, value string) {
+	section, name, _ := splitKey(key)
+	text := "\t" + name + " = " + formatValue(value)
+
+	last := -1
+	sectionEnd := -1
+	for i, line := range f.lines {
+		if line.key == key {
+			last = i
+		}
+		if line.section == section {
+			sectionEnd = i
+		}
+	}
+
+	entry := line{text: text, section: section, key: key, value: value}
+	switch {
+	case last >= 0:
+		f.lines[last] = entry
+	case sectionEnd >= 0:
+		f.lines = append(f.lines[:sectionEnd+1], append([]line{entry}, f.lines[sectionEnd+1:]...)...)
+	default:
+		f.lines = append(f.lines, line{text: "[" + section + "]", section: section}, entry)
+	}
+}
+
+// unset removes every line setting key, and the section header too once
+// nothing is left under it.
+func (f *File) unset(key string) bool {
+	section, _, _ := splitKey(key)
+	kept := f.lines[:0]
+	removed := false
+	for _, line := range f.lines {
+		if line.key == key {
+			removed = true
+			continue
+		}
+		kept = append(kept, line)
+	}
+	f.lines = kept
+
+	if removed {
+		for i, line := range f.lines {
+			if line.section != section || line.key != "" {
+				continue
+			}
+			if i+1 == len(f.lines) || (f.lines[i+1].key == "" && f.lines[i+1].section != "") {
+				f.lines = append(f.lines[:i], f.lines[i+1:]...)
+				break
+			}
+		}
+	}
+	return removed
+}
+
+func (f *File) Write() error {
+	var b strings.Builder
+	for _, line := range f.lines {
+		b.WriteString(line.text)
+		b.WriteString("\n")
+	}
+	if err := os.MkdirAll(filepath.Dir(f.Path), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	if err := utils.WriteFileAtomic(f.Path, []byte(b.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	return nil
+}
+
+// parseValue trims a value and drops a trailing comment. Quoted values
+// keep their spaces and may contain "#" and ";".
+func parseValue(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(value, "\"") {
+		end := closingQuote(value)
+		if end < 0 {
+			return "", fmt.Errorf("unterminated quoted value")
+		}
+		return strconv.Unquote(value[:end+1])
+	}
+	for i := 0; i < len(value); i++ {
+		if (value[i] == '#' || value[i] == ';') && (i == 0 || value[i-1] == ' ' || value[i-1] == '\t') {
+			return strings.TrimSpace(value[:i]), nil
+		}
+	}
+	return value, nil
+}
+
+func closingQuote(value string) int {
+	for i := 1; i < len(value); i++ {
+		switch value[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+func formatValue(value string) string {
+	if value == "" || value != strings.TrimSpace(value) || strings.ContainsAny(value, "#;\"\\\n") {
+		return strconv.Quote(value)
+	}
+	return value
+}
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jolovicdev/nora/internal/core/diff"
+)
+
+// Key describes a setting: the environment variable overriding it and
+// the values it accepts when they are limited.
+type Key struct {
+	Name   string
+	Env    string
+	Values []string
+	Help   string
+}
+
+// Keys lists every fixed setting. Aliases are open-ended and live under
+// "alias.<name>".
+var Keys = []Key{
+	{Name: "user.name", Env: "NORA_USER_NAME", Help: "name recorded on snapshots"},
+	{Name: "user.email", Env: "NORA_USER_EMAIL", Help: "email recorded on snapshots"},
+	{Name: "diff.algorithm", Env: "NORA_DIFF_ALGORITHM", Values: diff.Algorithms(), Help: "default diff algorithm"},
+	{Name: "color.ui", Env: "NORA_COLOR", Values: []string{"auto", "always", "never"}, Help: "when to colour output"},
+	{Name: "core.pager", Env: "NORA_PAGER", Help: "command that pages history and diff on a terminal; empty or cat turns it off"},
+	{Name: "ignore.patterns", Env: "NORA_IGNORE", Help: "whitespace-separated patterns ignored on top of .noraignore files"},
+}
+
+const AliasSection = "alias"
+
+// LookupKey returns the definition of a fixed key or an alias.
+func LookupKey(name string) (*Key, error) {
+	for i := range Keys {
+		if Keys[i].Name == name {
+			return &Keys[i], nil
+		}
+	}
+
+	section, alias, err := splitKey(name)
+	if err != nil {
+		return nil, err
+	}
+	if section == AliasSection {
+		if strings.ContainsAny(alias, " \t.") {
+			return nil, fmt.Errorf("invalid alias name %q", alias)
+		}
+		return &Key{Name: name, Help: "command run by nora " + alias}, nil
+	}
+	return nil, fmt.Errorf("unknown config key %q", name)
+}
+
+func (k *Key) Validate(value string) error {
+	if len(k.Values) == 0 {
+		return nil
+	}
+	for _, allowed := range k.Values {
+		if strings.EqualFold(value, allowed) {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid value %q for %s (expected %s)", value, k.Name, strings.Join(k.Values, ", "))
+}
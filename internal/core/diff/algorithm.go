@@ -0,0 +1,266 @@
+package diff
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jolovicdev/nora/internal/types"
+)
+
+const DefaultAlgorithm = "myers"
+
+type Algorithm interface {
+	Name() string
+	Diff(oldLines, newLines []string) []types.DiffStep
+}
+
+type Myers struct{}
+
+func (Myers) Name() string { return "myers" }
+
+func (Myers) Diff(oldLines, newLines []string) []types.DiffStep {
+	return SimpleMyers(oldLines, newLines)
+}
+
+func Algorithms() []string {
+	return []string{"myers", "patience", "histogram"}
+}
+
+func Lookup(name string) (Algorithm, error) {
+	switch strings.ToLower(name) {
+	case "", "myers", "default":
+		return Myers{}, nil
+	case "patience":
+		return Patience{}, nil
+	case "histogram":
+		return Histogram{}, nil
+	default:
+		return nil, fmt.Errorf("unknown diff algorithm %q (available: %s)", name, strings.Join(Algorithms(), ", "))
+	}
+}
+
+type script struct {
+	a, b  []string
+	steps []types.DiffStep
+}
+
+func newScript(a, b []string) *script {
+	return &script{a: a, b: b, steps: make([]types.DiffStep, 0, len(a)+len(b))}
+}
+
+func (s *script) keep(x int) {
+	s.steps = append(s.steps, types.DiffStep{Type: "keep", Content: s.a[x], Position: x})
+}
+
+// trim emits the common prefix of both ranges and returns the narrowed
+// ranges together with the length of the common suffix, which the caller
+// emits once the middle is done.
+func (s *script) trim(aLo, aHi, bLo, bHi int) (int, int, int, int, int) {
+	for aLo < aHi && bLo < bHi && s.a[aLo] == s.b[bLo] {
+		s.keep(aLo)
+		aLo++
+		bLo++
+	}
+	suffix := 0
+	for aHi > aLo && bHi > bLo && s.a[aHi-1] == s.b[bHi-1] {
+		aHi--
+		bHi--
+		suffix++
+	}
+	return aLo, aHi, bLo, bHi, suffix
+}
+
+func (s *script) keepSuffix(aHi, suffix int) {
+	for i := 0; i < suffix; i++ {
+		s.keep(aHi + i)
+	}
+}
+
+func (s *script) myers(aLo, aHi, bLo, bHi int) {
+	for _, step := range SimpleMyers(s.a[aLo:aHi], s.b[bLo:bHi]) {
+		if step.Type == "add" {
+			step.Position += bLo
+		} else {
+			step.Position += aLo
+		}
+		s.steps = append(s.steps, step)
+	}
+}
+
+func (s *script) result() []types.DiffStep {
+	groupChanges(s.steps)
+	return s.steps
+}
+
+type Patience struct{}
+
+func (Patience) Name() string { return "patience" }
+
+func (Patience) Diff(oldLines, newLines []string) []types.DiffStep {
+	s := newScript(oldLines, newLines)
+	s.patience(0, len(oldLines), 0, len(newLines))
+	return s.result()
+}
+
+func (s *script) patience(aLo, aHi, bLo, bHi int) {
+	aLo, aHi, bLo, bHi, suffix := s.trim(aLo, aHi, bLo, bHi)
+
+	anchors := s.uniqueAnchors(aLo, aHi, bLo, bHi)
+	if len(anchors) == 0 {
+		s.myers(aLo, aHi, bLo, bHi)
+	} else {
+		x, y := aLo, bLo
+		for _, anchor := range anchors {
+			s.patience(x, anchor[0], y, anchor[1])
+			s.keep(anchor[0])
+			x, y = anchor[0]+1, anchor[1]+1
+		}
+		s.patience(x, aHi, y, bHi)
+	}
+
+	s.keepSuffix(aHi, suffix)
+}
+
+// uniqueAnchors returns the longest increasing sequence of line pairs that
+// occur exactly once in both ranges.
+func (s *script) uniqueAnchors(aLo, aHi, bLo, bHi int) [][2]int {
+	type occurrence struct {
+		countA, countB int
+		posA, posB     int
+	}
+	lines := make(map[string]*occurrence)
+	for x := aLo; x < aHi; x++ {
+		o, ok := lines[s.a[x]]
+		if !ok {
+			o = &occurrence{}
+			lines[s.a[x]] = o
+		}
+		o.countA++
+		o.posA = x
+	}
+	for y := bLo; y < bHi; y++ {
+		if o, ok := lines[s.b[y]]; ok {
+			o.countB++
+			o.posB = y
+		}
+	}
+
+	var pairs [][2]int
+	for _, o := range lines {
+		if o.countA == 1 && o.countB == 1 {
+			pairs = append(pairs, [2]int{o.posA, o.posB})
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i][0] < pairs[j][0] })
+	return longestIncreasing(pairs)
+}
+
+func longestIncreasing(pairs [][2]int) [][2]int {
+	if len(pairs) == 0 {
+		return nil
+	}
+
+	var tails []int
+	prev := make([]int, len(pairs))
+	for i, pair := range pairs {
+		j := sort.Search(len(tails), func(t int) bool { return pairs[tails[t]][1] >= pair[1] })
+		if j > 0 {
+			prev[i] = tails[j-1]
+		} else {
+			prev[i] = -1
+		}
+		if j == len(tails) {
+			tails = append(tails, i)
+		} else {
+			tails[j] = i
+		}
+	}
+
+	result := make([][2]int, len(tails))
+	for i, k := len(tails)-1, tails[len(tails)-1]; i >= 0; i, k = i-1, prev[k] {
+		result[i] = pairs[k]
+	}
+	return result
+}
+
+const histogramMaxChain = 64
+
+type Histogram struct{}
+
+func (Histogram) Name() string { return "histogram" }
+
+func (Histogram) Diff(oldLines, newLines []string) []types.DiffStep {
+	s := newScript(oldLines, newLines)
+	s.histogram(0, len(oldLines), 0, len(newLines))
+	return s.result()
+}
+
+func (s *script) histogram(aLo, aHi, bLo, bHi int) {
+	aLo, aHi, bLo, bHi, suffix := s.trim(aLo, aHi, bLo, bHi)
+
+	if aLo == aHi || bLo == bHi {
+		s.myers(aLo, aHi, bLo, bHi)
+	} else if x, y, length, ok := s.lowestOccurrenceRegion(aLo, aHi, bLo, bHi); !ok {
+		s.myers(aLo, aHi, bLo, bHi)
+	} else {
+		s.histogram(aLo, x, bLo, y)
+		for i := 0; i < length; i++ {
+			s.keep(x + i)
+		}
+		s.histogram(x+length, aHi, y+length, bHi)
+	}
+
+	s.keepSuffix(aHi, suffix)
+}
+
+// lowestOccurrenceRegion finds the common region whose rarest line
+// occurs least often in the old range, preferring the longer region on a
+// tie, the way git's histogram diff picks its split points. Once a region
+// is found the scan resumes after it, so each line of the new range
+// seeds at most one extension.
+func (s *script) lowestOccurrenceRegion(aLo, aHi, bLo, bHi int) (int, int, int, bool) {
+	occurrences := make(map[string][]int)
+	for x := aLo; x < aHi; x++ {
+		occurrences[s.a[x]] = append(occurrences[s.a[x]], x)
+	}
+
+	bestX, bestY, bestLen, bestCount := 0, 0, 0, histogramMaxChain+1
+	for y := bLo; y < bHi; {
+		next := y + 1
+		positions, ok := occurrences[s.b[y]]
+		if !ok || len(positions) > bestCount {
+			y = next
+			continue
+		}
+		for i := 0; i < len(positions); {
+			x := positions[i]
+			minCount := len(positions)
+			start, startY := x, y
+			for start > aLo && startY > bLo && s.a[start-1] == s.b[startY-1] {
+				start--
+				startY--
+				minCount = min(minCount, len(occurrences[s.a[start]]))
+			}
+			end, endY := x+1, y+1
+			for end < aHi && endY < bHi && s.a[end] == s.b[endY] {
+				minCount = min(minCount, len(occurrences[s.a[end]]))
+				end++
+				endY++
+			}
+			next = max(next, endY)
+
+			length := end - start
+			if minCount < bestCount || (minCount == bestCount && length > bestLen) {
+				bestX, bestY, bestLen, bestCount = start, startY, length, minCount
+			}
+			// Later occurrences inside this region would only find it again.
+			for i < len(positions) && positions[i] < end {
+				i++
+			}
+		}
+		y = next
+	}
+
+	return bestX, bestY, bestLen, bestLen > 0
+}
@@ -0,0 +1,131 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+)
+
+// hunks renders a diff with no context and without the file header, so
+// expectations only show what changed.
+func hunks(oldLines, newLines []string, algorithm Algorithm) string {
+	out := Unified("a", "b", oldLines, newLines, algorithm.Diff(oldLines, newLines), UnifiedOptions{})
+	_, body, _ := strings.Cut(out, "+++ b\n")
+	return body
+}
+
+func TestAlgorithmsOnTrickyInputs(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		want     map[string]string
+	}{
+		{
+			name: "moved block",
+			old:  "alpha\nbeta\ngamma\ndelta\nx\nx\nepsilon\n",
+			new:  "delta\nx\nx\nalpha\nbeta\ngamma\nepsilon\n",
+			want: map[string]string{
+				"myers":     "@@ -1,3 +0,0 @@\n-alpha\n-beta\n-gamma\n@@ -6,0 +4,3 @@\n+alpha\n+beta\n+gamma\n",
+				"patience":  "@@ -0,0 +1,3 @@\n+delta\n+x\n+x\n@@ -4,3 +6,0 @@\n-delta\n-x\n-x\n",
+				"histogram": "@@ -1,3 +0,0 @@\n-alpha\n-beta\n-gamma\n@@ -6,0 +4,3 @@\n+alpha\n+beta\n+gamma\n",
+			},
+		},
+		{
+			name: "brace-only lines",
+			old:  "if (a) {\n  x();\n}\nif (b) {\n  y();\n}\n",
+			new:  "if (a) {\n  x();\n  if (c) {\n    z();\n  }\n}\nif (b) {\n  y();\n}\n",
+			want: map[string]string{
+				"myers":     "@@ -2,0 +3,3 @@\n+  if (c) {\n+    z();\n+  }\n",
+				"patience":  "@@ -2,0 +3,3 @@\n+  if (c) {\n+    z();\n+  }\n",
+				"histogram": "@@ -2,0 +3,3 @@\n+  if (c) {\n+    z();\n+  }\n",
+			},
+		},
+		{
+			name: "function inserted between braces",
+			old:  "f() {\n  a();\n}\n}\ng() {\n  b();\n}\n",
+			new:  "f() {\n  a();\n}\nh() {\n  c();\n}\n}\ng() {\n  b();\n}\n",
+			want: map[string]string{
+				"myers":     "@@ -3,0 +4,3 @@\n+h() {\n+  c();\n+}\n",
+				"patience":  "@@ -3,0 +4,3 @@\n+h() {\n+  c();\n+}\n",
+				"histogram": "@@ -3,0 +4,3 @@\n+h() {\n+  c();\n+}\n",
+			},
+		},
+		{
+			name: "repeated lines",
+			old:  "x\nx\nx\ny\nx\nx\n",
+			new:  "x\ny\nx\nx\nx\nx\n",
+			want: map[string]string{
+				"myers":     "@@ -1,0 +2 @@\n+y\n@@ -4 +4,0 @@\n-y\n",
+				"patience":  "@@ -2,2 +1,0 @@\n-x\n-x\n@@ -4,0 +3,2 @@\n+x\n+x\n",
+				"histogram": "@@ -2,2 +1,0 @@\n-x\n-x\n@@ -4,0 +3,2 @@\n+x\n+x\n",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		for _, name := range Algorithms() {
+			t.Run(tt.name+"/"+name, func(t *testing.T) {
+				algorithm, err := Lookup(name)
+				if err != nil {
+					t.Fatal(err)
+				}
+				oldLines, newLines := SplitLines(tt.old), SplitLines(tt.new)
+				checkScript(t, oldLines, newLines, algorithm.Diff(oldLines, newLines))
+				if got := hunks(oldLines, newLines, algorithm); got != tt.want[name] {
+					t.Errorf("got hunks\n%s\nwant\n%s", got, tt.want[name])
+				}
+			})
+		}
+	}
+}
+
+func TestAlgorithmsRebuildRandomInputs(t *testing.T) {
+	lines := []string{"{\n", "}\n", "\n", "a\n", "b\n", "return\n"}
+	for seed := 0; seed < 200; seed++ {
+		oldLines := make([]string, seed%23)
+		newLines := make([]string, (seed*7)%19)
+		for i := range oldLines {
+			oldLines[i] = lines[(seed+i*i)%len(lines)]
+		}
+		for i := range newLines {
+			newLines[i] = lines[(seed*3+i)%len(lines)]
+		}
+		for _, name := range Algorithms() {
+			algorithm, _ := Lookup(name)
+			checkScript(t, oldLines, newLines, algorithm.Diff(oldLines, newLines))
+		}
+	}
+}
+
+// A large file changed only at both ends used to take seconds with
+// histogram, which re-extended the same common region from every line.
+func TestAlgorithmsLargeFileEditedAtEnds(t *testing.T) {
+	oldLines := numberedLines(20000, "old")
+	newLines := append([]string(nil), oldLines...)
+	newLines[0] = "first\n"
+	newLines[len(newLines)-1] = "last\n"
+	for _, name := range Algorithms() {
+		t.Run(name, func(t *testing.T) {
+			algorithm, _ := Lookup(name)
+			steps := algorithm.Diff(oldLines, newLines)
+			checkScript(t, oldLines, newLines, steps)
+			if got := countEdits(steps); got != 4 {
+				t.Errorf("got %d edits, want 4", got)
+			}
+		})
+	}
+}
+
+func benchmarkEditedAtEnds(b *testing.B, algorithm Algorithm) {
+	oldLines := numberedLines(20000, "old")
+	newLines := append([]string(nil), oldLines...)
+	newLines[0] = "first\n"
+	newLines[len(newLines)-1] = "last\n"
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		algorithm.Diff(oldLines, newLines)
+	}
+}
+
+func BenchmarkMyersEditedAtEnds(b *testing.B)     { benchmarkEditedAtEnds(b, Myers{}) }
+func BenchmarkPatienceEditedAtEnds(b *testing.B)  { benchmarkEditedAtEnds(b, Patience{}) }
+func BenchmarkHistogramEditedAtEnds(b *testing.B) { benchmarkEditedAtEnds(b, Histogram{}) }
@@ -1,107 +1,166 @@
 package diff
 
-import (
-	"strings"
-
-	"github.com/jolovicdev/nora/internal/types"
-)
+import "github.com/jolovicdev/nora/internal/types"
 
+type myers struct {
+	a, b  []string
+	vf    []int
+	vb    []int
+	steps []types.DiffStep
+}
 
+// SimpleMyers uses the linear-space divide-and-conquer variant of Myers'
+// algorithm: each step finds the middle snake of the remaining edit graph
+// and recurses on both halves, so memory stays O(N+M).
 func SimpleMyers(oldText, newText []string) []types.DiffStep {
-	n := len(oldText)
-	m := len(newText)
-	max := n + m
+	size := 2*((len(oldText)+len(newText)+1)/2) + 3
+	m := &myers{
+		a:     oldText,
+		b:     newText,
+		vf:    make([]int, size),
+		vb:    make([]int, size),
+		steps: make([]types.DiffStep, 0, len(oldText)+len(newText)),
+	}
+	m.compare(0, len(oldText), 0, len(newText))
+	groupChanges(m.steps)
+	return m.steps
+}
+
+func (m *myers) compare(aLo, aHi, bLo, bHi int) {
+	for aLo < aHi && bLo < bHi && m.a[aLo] == m.b[bLo] {
+		m.keep(aLo)
+		aLo++
+		bLo++
+	}
 
-	v := make(map[int]int)
-	v[1] = 0
-	trace := make([]map[int]int, 0)
+	suffix := 0
+	for aHi > aLo && bHi > bLo && m.a[aHi-1] == m.b[bHi-1] {
+		aHi--
+		bHi--
+		suffix++
+	}
 
-	for d := 0; d <= max; d++ {
-		vCopy := make(map[int]int)
-		for k, v := range v {
-			vCopy[k] = v
+	switch {
+	case aLo == aHi:
+		for y := bLo; y < bHi; y++ {
+			m.add(y)
+		}
+	case bLo == bHi:
+		for x := aLo; x < aHi; x++ {
+			m.delete(x)
+		}
+	default:
+		x, y, u, v := m.middleSnake(aLo, aHi, bLo, bHi)
+		m.compare(aLo, x, bLo, y)
+		for ; x < u; x++ {
+			m.keep(x)
 		}
-		trace = append(trace, vCopy)
+		m.compare(u, aHi, v, bHi)
+	}
+
+	for i := 0; i < suffix; i++ {
+		m.keep(aHi + i)
+	}
+}
+
+func (m *myers) middleSnake(aLo, aHi, bLo, bHi int) (int, int, int, int) {
+	n, mm := aHi-aLo, bHi-bLo
+	delta := n - mm
+	odd := delta&1 != 0
+	max := (n + mm + 1) / 2
+	offset := max + 1
+	vf, vb := m.vf, m.vb
+	vf[offset+1] = 0
+	vb[offset+1] = 0
 
+	for d := 0; d <= max; d++ {
 		for k := -d; k <= d; k += 2 {
 			var x int
-			if k == -d || (k != d && v[k-1] < v[k+1]) {
-				x = v[k+1]
+			if k == -d || (k != d && vf[offset+k-1] < vf[offset+k+1]) {
+				x = vf[offset+k+1]
 			} else {
-				x = v[k-1] + 1
+				x = vf[offset+k-1] + 1
 			}
-
 			y := x - k
-
-			for x < n && y < m && oldText[x] == newText[y] {
+			sx, sy := x, y
+			for x < n && y < mm && m.a[aLo+x] == m.b[bLo+y] {
 				x++
 				y++
 			}
+			vf[offset+k] = x
+
+			if c := delta - k; odd && c >= -(d-1) && c <= d-1 && x+vb[offset+c] >= n {
+				return aLo + sx, bLo + sy, aLo + x, bLo + y
+			}
+		}
 
-			v[k] = x
+		for c := -d; c <= d; c += 2 {
+			var x int
+			if c == -d || (c != d && vb[offset+c-1] < vb[offset+c+1]) {
+				x = vb[offset+c+1]
+			} else {
+				x = vb[offset+c-1] + 1
+			}
+			y := x - c
+			sx, sy := x, y
+			for x < n && y < mm && m.a[aHi-1-x] == m.b[bHi-1-y] {
+				x++
+				y++
+			}
+			vb[offset+c] = x
 
-			if x >= n && y >= m {
-				return Backtrack(trace, oldText, newText)
+			if k := delta - c; !odd && k >= -d && k <= d && x+vf[offset+k] >= n {
+				return aLo + n - x, bLo + mm - y, aLo + n - sx, bLo + mm - sy
 			}
 		}
 	}
 
-	return nil
+	return aLo, bLo, aLo, bLo
+}
+
+func (m *myers) keep(x int) {
+	m.steps = append(m.steps, types.DiffStep{Type: "keep", Content: m.a[x], Position: x})
 }
 
-func Backtrack(trace []map[int]int, oldText, newText []string) []types.DiffStep {
-	steps := make([]types.DiffStep, 0)
-	x := len(oldText)
-	y := len(newText)
-	
-	for d := len(trace) - 1; d >= 0; d-- {
-		v := trace[d]
-		k := x - y
-		
-		var prevK int
-		if k == -d || (k != d && v[k-1] < v[k+1]) {
-			prevK = k + 1
-		} else {
-			prevK = k - 1
+func (m *myers) delete(x int) {
+	m.steps = append(m.steps, types.DiffStep{Type: "delete", Content: m.a[x], Position: x})
+}
+
+func (m *myers) add(y int) {
+	m.steps = append(m.steps, types.DiffStep{Type: "add", Content: m.b[y], Position: y})
+}
+
+// groupChanges reorders every run of consecutive edits so that deletions
+// come before additions, which keeps hunks readable without changing the
+// edit script.
+func groupChanges(steps []types.DiffStep) {
+	var buf []types.DiffStep
+	for i := 0; i < len(steps); {
+		if steps[i].Type == "keep" {
+			i++
+			continue
 		}
-		
-		prevX := v[prevK]
-		prevY := prevX - prevK
-		
-		for x > prevX && y > prevY {
-			steps = append([]types.DiffStep{{
-				Type:     "keep",
-				Content:  oldText[x-1],
-				Position: x - 1,
-			}}, steps...)
-			x--
-			y--
+		j := i
+		for j < len(steps) && steps[j].Type != "keep" {
+			j++
 		}
-		
-		if d > 0 {
-			if x > prevX {
-				steps = append([]types.DiffStep{{
-					Type:     "delete",
-					Content:  oldText[x-1],
-					Position: x - 1,
-				}}, steps...)
-				x--
-			} else if y > prevY {
-				steps = append([]types.DiffStep{{
-					Type:     "add",
-					Content:  newText[y-1],
-					Position: y - 1,
-				}}, steps...)
-				y--
+
+		buf = buf[:0]
+		for _, step := range steps[i:j] {
+			if step.Type == "delete" {
+				buf = append(buf, step)
 			}
 		}
+		for _, step := range steps[i:j] {
+			if step.Type == "add" {
+				buf = append(buf, step)
+			}
+		}
+		copy(steps[i:j], buf)
+		i = j
 	}
-	
-	return steps
 }
+
 func CalculateDiff(oldContent, newContent string) []types.DiffStep {
-	oldLines := strings.Split(oldContent, "\n")
-	newLines := strings.Split(newContent, "\n")
-	
-	return SimpleMyers(oldLines, newLines)
-}
\ No newline at end of file
+	return SimpleMyers(SplitLines(oldContent), SplitLines(newContent))
+}
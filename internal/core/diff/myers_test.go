@@ -0,0 +1,197 @@
+package diff
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/jolovicdev/nora/internal/types"
+)
+
+// checkScript verifies that steps walk both texts in order: keep and
+// delete steps replay the old text, keep and add steps rebuild the new one.
+func checkScript(t *testing.T, oldLines, newLines []string, steps []types.DiffStep) {
+	t.Helper()
+	var oldOut, newOut []string
+	for i, step := range steps {
+		switch step.Type {
+		case "keep":
+			if step.Position != len(oldOut) {
+				t.Fatalf("step %d: keep at old position %d, want %d", i, step.Position, len(oldOut))
+			}
+			oldOut = append(oldOut, step.Content)
+			newOut = append(newOut, step.Content)
+		case "delete":
+			if step.Position != len(oldOut) {
+				t.Fatalf("step %d: delete at old position %d, want %d", i, step.Position, len(oldOut))
+			}
+			oldOut = append(oldOut, step.Content)
+		case "add":
+			if step.Position != len(newOut) {
+				t.Fatalf("step %d: add at new position %d, want %d", i, step.Position, len(newOut))
+			}
+			newOut = append(newOut, step.Content)
+		default:
+			t.Fatalf("step %d: unknown type %q", i, step.Type)
+		}
+	}
+	if !equalLines(oldOut, oldLines) {
+		t.Fatalf("script replays old text as %q, want %q", oldOut, oldLines)
+	}
+	if !equalLines(newOut, newLines) {
+		t.Fatalf("script rebuilds new text as %q, want %q", newOut, newLines)
+	}
+}
+
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func countEdits(steps []types.DiffStep) int {
+	edits := 0
+	for _, step := range steps {
+		if step.Type != "keep" {
+			edits++
+		}
+	}
+	return edits
+}
+
+// minEdits is the textbook LCS table, used as a reference for the
+// shortest edit script.
+func minEdits(a, b []string) int {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	return len(a) + len(b) - 2*lcs[0][0]
+}
+
+func split(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, " ")
+}
+
+func TestMyersKnownInputs(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		edits    int
+	}{
+		{"empty", "", "", 0},
+		{"insert into empty", "", "a b c", 3},
+		{"delete everything", "a b c", "", 3},
+		{"equal", "a b c", "a b c", 0},
+		{"classic", "a b c a b b a", "c b a b a c", 5},
+		{"change in middle", "a b c d e", "a b x d e", 2},
+		{"disjoint", "a b c", "x y z", 6},
+		{"prefix and suffix", "a b c", "z a b c z", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			oldLines, newLines := split(tt.old), split(tt.new)
+			steps := SimpleMyers(oldLines, newLines)
+			checkScript(t, oldLines, newLines, steps)
+			if got := countEdits(steps); got != tt.edits {
+				t.Errorf("got %d edits, want %d", got, tt.edits)
+			}
+		})
+	}
+}
+
+func TestMyersIsMinimal(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	alphabet := []string{"a", "b", "c", "d"}
+	random := func() []string {
+		lines := make([]string, rng.Intn(30))
+		for i := range lines {
+			lines[i] = alphabet[rng.Intn(len(alphabet))]
+		}
+		return lines
+	}
+
+	for i := 0; i < 500; i++ {
+		oldLines, newLines := random(), random()
+		steps := SimpleMyers(oldLines, newLines)
+		checkScript(t, oldLines, newLines, steps)
+		if got, want := countEdits(steps), minEdits(oldLines, newLines); got != want {
+			t.Fatalf("%q -> %q: got %d edits, want %d", oldLines, newLines, got, want)
+		}
+	}
+}
+
+func TestMyersGroupsDeletesBeforeAdds(t *testing.T) {
+	steps := SimpleMyers(split("a x y b"), split("a p q b"))
+	var kinds []string
+	for _, step := range steps {
+		kinds = append(kinds, step.Type)
+	}
+	want := "keep delete delete add add keep"
+	if got := strings.Join(kinds, " "); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func numberedLines(n int, prefix string) []string {
+	lines := make([]string, n)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("%s line %d\n", prefix, i)
+	}
+	return lines
+}
+
+// withEdits changes every step-th line.
+func withEdits(lines []string, step int) []string {
+	edited := append([]string(nil), lines...)
+	for i := step / 2; i < len(edited); i += step {
+		edited[i] = "edited " + edited[i]
+	}
+	return edited
+}
+
+func BenchmarkMyersLargeFewEdits(b *testing.B) {
+	oldLines := numberedLines(20000, "old")
+	newLines := withEdits(oldLines, 2000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		SimpleMyers(oldLines, newLines)
+	}
+}
+
+func BenchmarkMyersDisjoint(b *testing.B) {
+	oldLines := numberedLines(2000, "old")
+	newLines := numberedLines(2000, "new")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		SimpleMyers(oldLines, newLines)
+	}
+}
+
+func BenchmarkMyers50kLines(b *testing.B) {
+	oldLines := numberedLines(50000, "old")
+	newLines := withEdits(oldLines, 500)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		SimpleMyers(oldLines, newLines)
+	}
+}
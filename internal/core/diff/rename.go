@@ -0,0 +1,118 @@
+package diff
+
+import (
+	"sort"
+
+	"github.com/jolovicdev/nora/internal/core/storage"
+	"github.com/jolovicdev/nora/internal/types"
+)
+
+const DefaultRenameThreshold = 0.5
+
+func Similarity(oldContent, newContent string) float64 {
+	if oldContent == newContent {
+		return 1
+	}
+
+	steps := CalculateDiff(oldContent, newContent)
+	kept, total := 0, 0
+	for _, step := range steps {
+		switch step.Type {
+		case "keep":
+			kept += 2
+			total += 2
+		default:
+			total++
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(kept) / float64(total)
+}
+
+// DetectRenames pairs deleted paths with added paths, first by identical
+// content hash and then by line similarity at or above threshold. Binary
+// files only pair up when identical.
+func DetectRenames(deleted, added map[string]string, load func(hash string) ([]byte, error), threshold float64) []types.Rename {
+	var renames []types.Rename
+	remainingDeleted := sortedKeys(deleted)
+	remainingAdded := sortedKeys(added)
+
+	used := make(map[string]bool)
+	var unmatched []string
+	for _, from := range remainingDeleted {
+		matched := false
+		for _, to := range remainingAdded {
+			if !used[to] && deleted[from] == added[to] {
+				renames = append(renames, types.Rename{From: from, To: to, Similarity: 1})
+				used[to] = true
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			unmatched = append(unmatched, from)
+		}
+	}
+
+	if threshold <= 0 || threshold > 1 {
+		threshold = DefaultRenameThreshold
+	}
+	contents := make(map[string]string)
+	skipped := make(map[string]bool)
+	read := func(hash string) (string, bool) {
+		if content, ok := contents[hash]; ok {
+			return content, true
+		}
+		if skipped[hash] {
+			return "", false
+		}
+		data, err := load(hash)
+		if err != nil || storage.IsBinary(data) {
+			skipped[hash] = true
+			return "", false
+		}
+		contents[hash] = string(data)
+		return contents[hash], true
+	}
+
+	for _, from := range unmatched {
+		oldContent, ok := read(deleted[from])
+		if !ok {
+			continue
+		}
+
+		best, bestScore := "", threshold
+		for _, to := range remainingAdded {
+			if used[to] {
+				continue
+			}
+			newContent, ok := read(added[to])
+			if !ok {
+				continue
+			}
+			if score := Similarity(oldContent, newContent); score >= bestScore && (best == "" || score > bestScore) {
+				best, bestScore = to, score
+			}
+		}
+		if best != "" {
+			renames = append(renames, types.Rename{From: from, To: best, Similarity: bestScore})
+			used[best] = true
+		}
+	}
+
+	sort.Slice(renames, func(i, j int) bool {
+		return renames[i].To < renames[j].To
+	})
+	return renames
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
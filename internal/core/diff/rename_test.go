@@ -0,0 +1,112 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/jolovicdev/nora/internal/types"
+)
+
+// blobs stands in for the content store, keyed by made-up hashes.
+type blobs map[string]string
+
+func (b blobs) load(hash string) ([]byte, error) {
+	content, ok := b[hash]
+	if !ok {
+		return nil, fmt.Errorf("no object %s", hash)
+	}
+	return []byte(content), nil
+}
+
+func lines(n int, prefix string) string {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "%s %d\n", prefix, i)
+	}
+	return b.String()
+}
+
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		want     float64
+	}{
+		{"identical", "a\nb\n", "a\nb\n", 1},
+		{"unrelated one-liners", "hi\n", "y\n", 0},
+		{"one of two lines kept", "a\nb\n", "a\nc\n", 0.5},
+		{"missing final newline", "a\nb\n", "a\nb", 0.5},
+		{"empty and not", "", "a\n", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Similarity(tt.old, tt.new); got != tt.want {
+				t.Errorf("Similarity(%q, %q) = %v, want %v", tt.old, tt.new, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectRenames(t *testing.T) {
+	edited := strings.Replace(lines(10, "line"), "line 4\n", "changed\n", 1)
+	rewritten := lines(3, "line") + lines(7, "other")
+	store := blobs{
+		"same":      lines(10, "line"),
+		"original":  lines(10, "line"),
+		"edited":    edited,
+		"rewritten": rewritten,
+		"hi":        "hi\n",
+		"y":         "y\n",
+		"bin1":      "\x00\x01\x02\nshared\nshared\nshared\n",
+		"bin2":      "\x00\x01\x03\nshared\nshared\nshared\n",
+	}
+
+	tests := []struct {
+		name           string
+		deleted, added map[string]string
+		want           []types.Rename
+	}{
+		{
+			name:    "exact match",
+			deleted: map[string]string{"a.txt": "same"},
+			added:   map[string]string{"b.txt": "same", "c.txt": "y"},
+			want:    []types.Rename{{From: "a.txt", To: "b.txt", Similarity: 1}},
+		},
+		{
+			name:    "above threshold",
+			deleted: map[string]string{"a.txt": "original"},
+			added:   map[string]string{"b.txt": "edited"},
+			want:    []types.Rename{{From: "a.txt", To: "b.txt", Similarity: 0.9}},
+		},
+		{
+			name:    "below threshold",
+			deleted: map[string]string{"a.txt": "original"},
+			added:   map[string]string{"b.txt": "rewritten"},
+		},
+		{
+			name:    "unrelated small files",
+			deleted: map[string]string{"d/g.txt": "hi"},
+			added:   map[string]string{"y.txt": "y"},
+		},
+		{
+			name:    "similar binaries",
+			deleted: map[string]string{"a.bin": "bin1"},
+			added:   map[string]string{"b.bin": "bin2"},
+		},
+		{
+			name:    "best candidate wins",
+			deleted: map[string]string{"a.txt": "original"},
+			added:   map[string]string{"b.txt": "rewritten", "c.txt": "edited"},
+			want:    []types.Rename{{From: "a.txt", To: "c.txt", Similarity: 0.9}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DetectRenames(tt.deleted, tt.added, store.load, DefaultRenameThreshold)
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jolovicdev/nora/internal/types"
+	"github.com/jolovicdev/nora/internal/utils"
+)
+
+const DefaultContext = 3
+
+type UnifiedOptions struct {
+	Context  int
+	Color    bool
+	WordDiff bool
+}
+
+type line struct {
+	Type    string
+	Content string
+	OldLine int
+	NewLine int
+}
+
+// SplitLines splits content into lines that keep their trailing newline,
+// so a final line without one compares unequal to the same line with one.
+func SplitLines(content string) []string {
+	if content == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(content, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+func Unified(oldName, newName string, oldLines, newLines []string, steps []types.DiffStep, opts UnifiedOptions) string {
+	lines := annotate(oldLines, newLines, steps)
+	hunks := groupHunks(lines, opts.Context)
+	if len(hunks) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString(colorize(fmt.Sprintf("--- %s\n+++ %s\n", oldName, newName), utils.Bold, opts.Color))
+	for _, hunk := range hunks {
+		writeHunk(&b, lines[hunk[0]:hunk[1]], opts)
+	}
+	return b.String()
+}
+
+func annotate(oldLines, newLines []string, steps []types.DiffStep) []line {
+	lines := make([]line, 0, len(steps))
+	oldIdx, newIdx := 0, 0
+	for _, step := range steps {
+		l := line{Type: step.Type, OldLine: oldIdx, NewLine: newIdx}
+		switch step.Type {
+		case "keep":
+			l.Content = oldLines[oldIdx]
+			oldIdx++
+			newIdx++
+		case "delete":
+			l.Content = oldLines[oldIdx]
+			oldIdx++
+		case "add":
+			l.Content = newLines[newIdx]
+			newIdx++
+		}
+		lines = append(lines, l)
+	}
+	return lines
+}
+
+func groupHunks(lines []line, context int) [][2]int {
+	if context < 0 {
+		context = DefaultContext
+	}
+
+	var hunks [][2]int
+	for i := 0; i < len(lines); i++ {
+		if lines[i].Type == "keep" {
+			continue
+		}
+
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+		if n := len(hunks); n > 0 && start <= hunks[n-1][1] {
+			start = hunks[n-1][0]
+			hunks = hunks[:n-1]
+		}
+
+		end := i
+		for end < len(lines) && lines[end].Type != "keep" {
+			end++
+		}
+		i = end - 1
+		end += context
+		if end > len(lines) {
+			end = len(lines)
+		}
+		hunks = append(hunks, [2]int{start, end})
+	}
+	return hunks
+}
+
+func writeHunk(b *strings.Builder, lines []line, opts UnifiedOptions) {
+	oldCount, newCount := 0, 0
+	for _, l := range lines {
+		if l.Type != "add" {
+			oldCount++
+		}
+		if l.Type != "delete" {
+			newCount++
+		}
+	}
+
+	oldStart, newStart := lines[0].OldLine, lines[0].NewLine
+	if oldCount > 0 {
+		oldStart++
+	}
+	if newCount > 0 {
+		newStart++
+	}
+	header := fmt.Sprintf("@@ -%s +%s @@\n", hunkRange(oldStart, oldCount), hunkRange(newStart, newCount))
+	b.WriteString(colorize(header, utils.Cyan, opts.Color))
+
+	for i := 0; i < len(lines); {
+		if lines[i].Type == "keep" {
+			if opts.WordDiff {
+				b.WriteString(strings.TrimSuffix(lines[i].Content, "\n") + "\n")
+			} else {
+				writeLine(b, " ", lines[i].Content, "", "", false)
+			}
+			i++
+			continue
+		}
+
+		var deleted, added []string
+		for ; i < len(lines) && lines[i].Type == "delete"; i++ {
+			deleted = append(deleted, lines[i].Content)
+		}
+		for ; i < len(lines) && lines[i].Type == "add"; i++ {
+			added = append(added, lines[i].Content)
+		}
+		writeChanges(b, deleted, added, opts)
+	}
+}
+
+func writeChanges(b *strings.Builder, deleted, added []string, opts UnifiedOptions) {
+	paired := len(deleted)
+	if len(added) < paired {
+		paired = len(added)
+	}
+
+	if opts.WordDiff {
+		for i := 0; i < paired; i++ {
+			b.WriteString(WordDiffLine(trimNewline(deleted[i]), trimNewline(added[i]), opts.Color) + "\n")
+		}
+		for _, content := range deleted[paired:] {
+			b.WriteString(wordMarker("[-", trimNewline(content), "-]", utils.Red, opts.Color) + "\n")
+		}
+		for _, content := range added[paired:] {
+			b.WriteString(wordMarker("{+", trimNewline(content), "+}", utils.Green, opts.Color) + "\n")
+		}
+		return
+	}
+
+	highlightedOld := make([]string, len(deleted))
+	highlightedNew := make([]string, len(added))
+	if opts.Color {
+		for i := 0; i < paired; i++ {
+			highlightedOld[i], highlightedNew[i] = HighlightPair(trimNewline(deleted[i]), trimNewline(added[i]), utils.Red, utils.Green)
+		}
+	}
+	for i, content := range deleted {
+		writeLine(b, "-", content, highlightedOld[i], utils.Red, opts.Color)
+	}
+	for i, content := range added {
+		writeLine(b, "+", content, highlightedNew[i], utils.Green, opts.Color)
+	}
+}
+
+func writeLine(b *strings.Builder, prefix, content, highlighted, color string, enabled bool) {
+	switch {
+	case highlighted != "":
+		b.WriteString(color + prefix + highlighted + utils.Reset)
+	default:
+		b.WriteString(colorize(prefix+trimNewline(content), color, enabled && color != ""))
+	}
+	b.WriteString("\n")
+	if !strings.HasSuffix(content, "\n") {
+		b.WriteString("\\ No newline at end of file\n")
+	}
+}
+
+func trimNewline(content string) string {
+	return strings.TrimSuffix(content, "\n")
+}
+
+func hunkRange(start, count int) string {
+	if count == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+func colorize(text, color string, enabled bool) string {
+	if !enabled {
+		return text
+	}
+	trimmed := strings.TrimSuffix(text, "\n")
+	return color + trimmed + utils.Reset + text[len(trimmed):]
+}
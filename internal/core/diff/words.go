@@ -0,0 +1,103 @@
+package diff
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/jolovicdev/nora/internal/utils"
+)
+
+const highlight = "\033[7m"
+
+// Tokenize splits a line into identifier-like words, runs of whitespace
+// and single punctuation characters.
+func Tokenize(line string) []string {
+	var tokens []string
+	runes := []rune(line)
+	for i := 0; i < len(runes); {
+		j := i + 1
+		switch {
+		case isWordRune(runes[i]):
+			for j < len(runes) && isWordRune(runes[j]) {
+				j++
+			}
+		case unicode.IsSpace(runes[i]):
+			for j < len(runes) && unicode.IsSpace(runes[j]) {
+				j++
+			}
+		}
+		tokens = append(tokens, string(runes[i:j]))
+		i = j
+	}
+	return tokens
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+type wordSpan struct {
+	Type string
+	Text string
+}
+
+func wordSpans(oldLine, newLine string) []wordSpan {
+	oldTokens, newTokens := Tokenize(oldLine), Tokenize(newLine)
+
+	var spans []wordSpan
+	for _, step := range SimpleMyers(oldTokens, newTokens) {
+		if n := len(spans); n > 0 && spans[n-1].Type == step.Type {
+			spans[n-1].Text += step.Content
+			continue
+		}
+		spans = append(spans, wordSpan{Type: step.Type, Text: step.Content})
+	}
+	return spans
+}
+
+func WordDiffLine(oldLine, newLine string, color bool) string {
+	var b strings.Builder
+	for _, span := range wordSpans(oldLine, newLine) {
+		switch span.Type {
+		case "keep":
+			b.WriteString(span.Text)
+		case "delete":
+			b.WriteString(wordMarker("[-", span.Text, "-]", utils.Red, color))
+		case "add":
+			b.WriteString(wordMarker("{+", span.Text, "+}", utils.Green, color))
+		}
+	}
+	return b.String()
+}
+
+func wordMarker(open, text, close, color string, enabled bool) string {
+	if !enabled {
+		return open + text + close
+	}
+	return color + open + text + close + utils.Reset
+}
+
+// HighlightPair renders a deleted and an added line with the tokens that
+// differ between them shown in reverse video on top of the line colour.
+// Lines with nothing but whitespace in common are returned empty so the
+// caller prints them unhighlighted.
+func HighlightPair(oldLine, newLine, oldColor, newColor string) (string, string) {
+	var oldOut, newOut strings.Builder
+	common := false
+	for _, span := range wordSpans(oldLine, newLine) {
+		switch span.Type {
+		case "keep":
+			common = common || strings.TrimSpace(span.Text) != ""
+			oldOut.WriteString(span.Text)
+			newOut.WriteString(span.Text)
+		case "delete":
+			oldOut.WriteString(highlight + span.Text + utils.Reset + oldColor)
+		case "add":
+			newOut.WriteString(highlight + span.Text + utils.Reset + newColor)
+		}
+	}
+	if !common {
+		return "", ""
+	}
+	return oldOut.String(), newOut.String()
+}
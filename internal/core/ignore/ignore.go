@@ -0,0 +1,300 @@
+package ignore
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const FileName = ".noraignore"
+
+// Rule is one pattern line. Source and Line point at where it was read
+// from; built-in defaults have an empty Source.
+type Rule struct {
+	Source  string
+	Line    int
+	Pattern string
+	Negate  bool
+
+	base    string
+	dirOnly bool
+	re      *regexp.Regexp
+}
+
+func (r *Rule) String() string {
+	source := r.Source
+	if source == "" {
+		source = "(default)"
+	}
+	return fmt.Sprintf("%s:%d:%s", source, r.Line, r.Pattern)
+}
+
+func (r *Rule) matches(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	return r.re.MatchString(rel)
+}
+
+// Matcher applies .noraignore files with .gitignore semantics: patterns
+// without a slash match a name at any depth, a leading or inner slash
+// anchors the pattern to its file's directory, a trailing slash matches
+// only directories, "**" spans directories and "!" re-includes. Rules in
+// deeper files and later lines win, and nothing below an ignored
+// directory can be re-included.
+//
+// Nested files are read lazily as paths below them are matched, so a
+// Matcher must not be shared between goroutines.
+type Matcher struct {
+	root   string
+	rules  map[string][]*Rule
+	loaded map[string]bool
+	dirs   map[string]*Rule
+}
+
+// New creates a matcher for the working tree at root. defaults act as if
+// they were listed before everything in the top-level .noraignore.
+func New(root string, defaults []string) (*Matcher, error) {
+	m := &Matcher{
+		root:   root,
+		rules:  make(map[string][]*Rule),
+		loaded: make(map[string]bool),
+		dirs:   make(map[string]*Rule),
+	}
+	for i, pattern := range defaults {
+		rule, err := parseRule(pattern, "")
+		if err != nil {
+			return nil, err
+		}
+		rule.Line = i + 1
+		m.rules[""] = append(m.rules[""], rule)
+	}
+	return m, nil
+}
+
+// Add appends patterns that act as if they were listed before everything
+// in the top-level .noraignore, after the defaults. Rules print as
+// source:n:pattern, n counting from one.
+func (m *Matcher) Add(source string, patterns []string) error {
+	for i, pattern := range patterns {
+		rule, err := parseRule(pattern, "")
+		if err != nil {
+			return fmt.Errorf("%s: %w", source, err)
+		}
+		if rule != nil {
+			rule.Source = source
+			rule.Line = i + 1
+			m.rules[""] = append(m.rules[""], rule)
+		}
+	}
+	return nil
+}
+
+// Match returns the rule deciding whether path is ignored, or nil when no
+// rule applies. A negated rule means the path is explicitly not ignored.
+// path is relative to the root in slash or OS form.
+func (m *Matcher) Match(file string, isDir bool) (*Rule, error) {
+	file = filepath.ToSlash(filepath.Clean(file))
+	if file == "." {
+		return nil, nil
+	}
+
+	parts := strings.Split(file, "/")
+	for i := 1; i < len(parts); i++ {
+		rule, err := m.matchDir(strings.Join(parts[:i], "/"))
+		if err != nil {
+			return nil, err
+		}
+		if rule != nil && !rule.Negate {
+			return rule, nil
+		}
+	}
+	if isDir {
+		return m.matchDir(file)
+	}
+	return m.match(file, false)
+}
+
+func (m *Matcher) Ignored(file string, isDir bool) (bool, error) {
+	rule, err := m.Match(file, isDir)
+	if err != nil {
+		return false, err
+	}
+	return rule != nil && !rule.Negate, nil
+}
+
+func (m *Matcher) matchDir(dir string) (*Rule, error) {
+	if rule, ok := m.dirs[dir]; ok {
+		return rule, nil
+	}
+	rule, err := m.match(dir, true)
+	if err != nil {
+		return nil, err
+	}
+	m.dirs[dir] = rule
+	return rule, nil
+}
+
+func (m *Matcher) match(file string, isDir bool) (*Rule, error) {
+	var found *Rule
+	base := ""
+	for {
+		if err := m.load(base); err != nil {
+			return nil, err
+		}
+		rel := file
+		if base != "" {
+			rel = strings.TrimPrefix(file, base+"/")
+		}
+		for _, rule := range m.rules[base] {
+			if rule.matches(rel, isDir) {
+				found = rule
+			}
+		}
+
+		next := strings.IndexByte(rel, '/')
+		if next < 0 {
+			return found, nil
+		}
+		base = path.Join(base, rel[:next])
+	}
+}
+
+func (m *Matcher) load(dir string) error {
+	if m.loaded[dir] {
+		return nil
+	}
+	m.loaded[dir] = true
+
+	source := path.Join(dir, FileName)
+	data, err := os.ReadFile(filepath.Join(m.root, filepath.FromSlash(source)))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read %s: %w", source, err)
+	}
+
+	for i, line := range strings.Split(string(data), "\n") {
+		rule, err := parseRule(line, dir)
+		if err != nil {
+			return fmt.Errorf("%s:%d: %w", source, i+1, err)
+		}
+		if rule != nil {
+			rule.Source = source
+			rule.Line = i + 1
+			m.rules[dir] = append(m.rules[dir], rule)
+		}
+	}
+	return nil
+}
+
+func parseRule(line, base string) (*Rule, error) {
+	line = strings.TrimRight(line, "\r")
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+		line = line[:len(line)-1]
+	}
+	if line == "" || line[0] == '#' {
+		return nil, nil
+	}
+
+	rule := &Rule{Pattern: line, base: base}
+	pattern := line
+	if pattern[0] == '!' {
+		rule.Negate = true
+		pattern = pattern[1:]
+	} else if strings.HasPrefix(pattern, "\\!") || strings.HasPrefix(pattern, "\\#") {
+		pattern = pattern[1:]
+	}
+	if strings.HasSuffix(pattern, "/") {
+		rule.dirOnly = true
+		pattern = strings.TrimRight(pattern, "/")
+	}
+	if pattern == "" {
+		return nil, nil
+	}
+
+	anchored := strings.Contains(pattern, "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+
+	expr := "^"
+	if !anchored {
+		expr += "(?:.*/)?"
+	}
+	expr += translate(pattern) + "$"
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", line, err)
+	}
+	rule.re = re
+	return rule, nil
+}
+
+// translate turns a glob into a regular expression where "*" and "?" stay
+// within one path segment and a "**" segment matches any number of them.
+func translate(pattern string) string {
+	var b strings.Builder
+	for i := 0; i < len(pattern); {
+		atSegment := i == 0 || pattern[i-1] == '/'
+		switch {
+		case atSegment && strings.HasPrefix(pattern[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 3
+		case atSegment && pattern[i:] == "**":
+			b.WriteString(".*")
+			i += 2
+		case pattern[i] == '*':
+			b.WriteString("[^/]*")
+			i++
+		case pattern[i] == '?':
+			b.WriteString("[^/]")
+			i++
+		case pattern[i] == '[':
+			class, n := translateClass(pattern[i:])
+			b.WriteString(class)
+			i += n
+		case pattern[i] == '\\' && i+1 < len(pattern):
+			b.WriteString(regexp.QuoteMeta(pattern[i+1 : i+2]))
+			i += 2
+		default:
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+			i++
+		}
+	}
+	return b.String()
+}
+
+// translateClass converts a bracket expression, treating an unterminated
+// "[" as a literal.
+func translateClass(pattern string) (string, int) {
+	i := 1
+	var b strings.Builder
+	b.WriteByte('[')
+	if i < len(pattern) && (pattern[i] == '!' || pattern[i] == '^') {
+		b.WriteByte('^')
+		i++
+	}
+	for first := true; i < len(pattern); first = false {
+		c := pattern[i]
+		if c == ']' && !first {
+			b.WriteByte(']')
+			return b.String(), i + 1
+		}
+		if c == '\\' && i+1 < len(pattern) {
+			i++
+			c = pattern[i]
+		}
+		if c == '/' {
+			break
+		}
+		if strings.IndexByte(`\[]^`, c) >= 0 {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(c)
+		i++
+	}
+	return `\[`, 1
+}
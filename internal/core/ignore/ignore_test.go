@@ -0,0 +1,245 @@
+package ignore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newMatcher writes the given .noraignore files, keyed by directory, into
+// a temporary working tree and returns a matcher for it.
+func newMatcher(t *testing.T, files map[string]string) *Matcher {
+	t.Helper()
+	root := t.TempDir()
+	for dir, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(dir), FileName)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m, err := New(root, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+type matchCase struct {
+	path    string
+	isDir   bool
+	ignored bool
+}
+
+func checkMatches(t *testing.T, m *Matcher, cases []matchCase) {
+	t.Helper()
+	for _, c := range cases {
+		ignored, err := m.Ignored(c.path, c.isDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ignored != c.ignored {
+			t.Errorf("Ignored(%q, dir=%v) = %v, want %v", c.path, c.isDir, ignored, c.ignored)
+		}
+	}
+}
+
+func TestMatcher(t *testing.T) {
+	tests := []struct {
+		name    string
+		ignore  string
+		matches []matchCase
+	}{
+		{
+			name:   "unanchored name matches at any depth",
+			ignore: "*.log\n",
+			matches: []matchCase{
+				{"a.log", false, true},
+				{"src/deep/a.log", false, true},
+				{"a.log.txt", false, false},
+				{"logs", true, false},
+			},
+		},
+		{
+			name:   "leading slash anchors to the file's directory",
+			ignore: "/build\n",
+			matches: []matchCase{
+				{"build", true, true},
+				{"build/out.o", false, true},
+				{"src/build", true, false},
+			},
+		},
+		{
+			name:   "inner slash anchors too",
+			ignore: "docs/*.pdf\n",
+			matches: []matchCase{
+				{"docs/a.pdf", false, true},
+				{"docs/sub/a.pdf", false, false},
+				{"other/docs/a.pdf", false, false},
+			},
+		},
+		{
+			name:   "double star spans directories",
+			ignore: "docs/**/*.pdf\n**/cache\nlogs/**\n",
+			matches: []matchCase{
+				{"docs/a.pdf", false, true},
+				{"docs/x/y/a.pdf", false, true},
+				{"src/docs/a.pdf", false, false},
+				{"cache", true, true},
+				{"a/b/cache", false, true},
+				{"logs/a/b.txt", false, true},
+				{"logs", true, false},
+			},
+		},
+		{
+			name:   "trailing slash matches directories only",
+			ignore: "out/\n",
+			matches: []matchCase{
+				{"out", true, true},
+				{"src/out", true, true},
+				{"out", false, false},
+				{"out/file", false, true},
+			},
+		},
+		{
+			name:   "negation re-includes",
+			ignore: "*.log\n!keep.log\n",
+			matches: []matchCase{
+				{"a.log", false, true},
+				{"keep.log", false, false},
+				{"sub/keep.log", false, false},
+			},
+		},
+		{
+			name:   "later lines win",
+			ignore: "!keep.log\n*.log\n",
+			matches: []matchCase{
+				{"keep.log", false, true},
+			},
+		},
+		{
+			name:   "no re-include below an ignored directory",
+			ignore: "build/\n!build/keep.txt\n",
+			matches: []matchCase{
+				{"build/keep.txt", false, true},
+			},
+		},
+		{
+			name:   "comments, blank lines and trailing spaces",
+			ignore: "# a comment\n\n*.tmp   \nname\\ \n",
+			matches: []matchCase{
+				{"# a comment", false, false},
+				{"a.tmp", false, true},
+				{"name ", false, true},
+				{"name", false, false},
+			},
+		},
+		{
+			name:   "escaped hash and bang are literal",
+			ignore: "\\#notes\n\\!important\n",
+			matches: []matchCase{
+				{"#notes", false, true},
+				{"!important", false, true},
+				{"important", false, false},
+			},
+		},
+		{
+			name:   "trailing hash is part of the pattern",
+			ignore: "*.log # old logs\n",
+			matches: []matchCase{
+				{"a.log", false, false},
+				{"a.log # old logs", false, true},
+			},
+		},
+		{
+			name:   "character classes and question marks",
+			ignore: "file[0-9].txt\n?.o\n[!a]*.c\n",
+			matches: []matchCase{
+				{"file3.txt", false, true},
+				{"filex.txt", false, false},
+				{"a.o", false, true},
+				{"ab.o", false, false},
+				{"b.c", false, true},
+				{"a.c", false, false},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkMatches(t, newMatcher(t, map[string]string{"": tt.ignore}), tt.matches)
+		})
+	}
+}
+
+func TestNestedIgnoreFiles(t *testing.T) {
+	m := newMatcher(t, map[string]string{
+		"":        "*.log\n/top.txt\n",
+		"src":     "!debug.log\n/gen\n",
+		"src/sub": "*.txt\n",
+	})
+	checkMatches(t, m, []matchCase{
+		{"a.log", false, true},
+		{"src/a.log", false, true},
+		{"src/debug.log", false, false},
+		{"src/deeper/debug.log", false, false},
+		{"debug.log", false, true},
+		{"top.txt", false, true},
+		{"src/top.txt", false, false},
+		{"src/gen", true, true},
+		{"src/sub/gen", true, false},
+		{"gen", true, false},
+		{"src/sub/notes.txt", false, true},
+		{"src/notes.txt", false, false},
+	})
+}
+
+func TestMatchReportsRule(t *testing.T) {
+	m := newMatcher(t, map[string]string{"": "*.log\n!keep.log\n", "sub": "/x\n"})
+	if err := m.Add("ignore.patterns", []string{"*.bak"}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"a.log", ".noraignore:1:*.log"},
+		{"keep.log", ".noraignore:2:!keep.log"},
+		{"sub/x", "sub/.noraignore:1:/x"},
+		{"a.bak", "ignore.patterns:1:*.bak"},
+		{"a.txt", "<nil>"},
+	}
+	for _, tt := range tests {
+		rule, err := m.Match(tt.path, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := "<nil>"
+		if rule != nil {
+			got = rule.String()
+		}
+		if got != tt.want {
+			t.Errorf("Match(%q) = %s, want %s", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestDefaults(t *testing.T) {
+	m, err := New(t.TempDir(), []string{".nora", "*.tmp"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkMatches(t, m, []matchCase{
+		{".nora", true, true},
+		{"a/b.tmp", false, true},
+		{"a.txt", false, false},
+	})
+	rule, err := m.Match("x.tmp", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rule.String(); got != "(default):2:*.tmp" {
+		t.Errorf("default rule prints as %s", got)
+	}
+}
@@ -0,0 +1,170 @@
+package layout
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Layout owns every path inside a story directory, so stores never build
+// paths of their own.
+type Layout struct {
+	root string
+}
+
+func New(root string) *Layout {
+	return &Layout{root: root}
+}
+
+func (l *Layout) Root() string {
+	return l.root
+}
+
+func (l *Layout) ObjectsDir() string {
+	return filepath.Join(l.root, "objects")
+}
+
+func (l *Layout) Object(hash string) string {
+	return filepath.Join(l.ObjectsDir(), hash[:2], hash[2:])
+}
+
+func (l *Layout) PackDir() string {
+	return filepath.Join(l.ObjectsDir(), "pack")
+}
+
+func (l *Layout) MemoriesDir() string {
+	return filepath.Join(l.root, "memories")
+}
+
+func (l *Layout) Memory(id string) string {
+	return filepath.Join(l.MemoriesDir(), id+".json")
+}
+
+func (l *Layout) IndexDir() string {
+	return filepath.Join(l.root, "index")
+}
+
+func (l *Layout) PreparedFiles() string {
+	return filepath.Join(l.IndexDir(), "prepared.json")
+}
+
+func (l *Layout) PreparedModes() string {
+	return filepath.Join(l.IndexDir(), "modes.json")
+}
+
+func (l *Layout) StatCache() string {
+	return filepath.Join(l.IndexDir(), "stat.json")
+}
+
+// Config is the story's own layer of settings, read by the config
+// package.
+func (l *Layout) Config() string {
+	return filepath.Join(l.root, "config")
+}
+
+// State holds the timelines and the current one.
+func (l *Layout) State() string {
+	return filepath.Join(l.root, "state.json")
+}
+
+func (l *Layout) TimelinesDir() string {
+	return filepath.Join(l.root, "timelines")
+}
+
+func (l *Layout) Timeline(name string) string {
+	return filepath.Join(l.TimelinesDir(), name+".json")
+}
+
+func (l *Layout) MetaDir() string {
+	return filepath.Join(l.root, "meta")
+}
+
+func (l *Layout) CaptureJournal() string {
+	return filepath.Join(l.MetaDir(), "capture.json")
+}
+
+func (l *Layout) Lock() string {
+	return filepath.Join(l.root, "lock")
+}
+
+// Dirs lists the directories a new story starts with.
+func (l *Layout) Dirs() []string {
+	return []string{
+		l.root,
+		l.MemoriesDir(),
+		l.TimelinesDir(),
+		l.IndexDir(),
+		l.MetaDir(),
+		l.ObjectsDir(),
+	}
+}
+
+// HasConfigDir reports whether MigrateConfigDir has anything to do.
+func (l *Layout) HasConfigDir() bool {
+	info, err := os.Stat(l.Config())
+	return err == nil && info.IsDir()
+}
+
+// MigrateConfigDir moves the state older versions kept in
+// config/config.json to State, freeing the config path for settings.
+func (l *Layout) MigrateConfigDir() error {
+	dir := l.Config()
+	legacy := filepath.Join(dir, "config.json")
+	if _, err := os.Stat(legacy); err == nil {
+		if err := os.Rename(legacy, l.State()); err != nil {
+			return fmt.Errorf("failed to move %s: %w", legacy, err)
+		}
+	}
+	if err := os.Remove(dir); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", dir, err)
+	}
+	return nil
+}
+
+// HasNested reports whether MigrateNested has anything to do.
+func (l *Layout) HasNested() bool {
+	info, err := os.Stat(filepath.Join(l.root, ".nora"))
+	return err == nil && info.IsDir()
+}
+
+// MigrateNested moves files that older versions wrote to a nested
+// ".nora" directory inside the story directory, where the timeline
+// manager used to keep config and timelines, to their proper place. The
+// nested copies were the ones in use, so they replace anything already
+// there. It reports whether anything was moved.
+func (l *Layout) MigrateNested() (bool, error) {
+	if !l.HasNested() {
+		return false, nil
+	}
+	nested := filepath.Join(l.root, ".nora")
+
+	var dirs []string
+	err := filepath.WalkDir(nested, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			dirs = append(dirs, path)
+			return nil
+		}
+
+		rel, err := filepath.Rel(nested, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(l.root, rel)
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		return os.Rename(path, target)
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to move %s: %w", nested, err)
+	}
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		os.Remove(dirs[i])
+	}
+	return true, nil
+}
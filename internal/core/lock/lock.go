@@ -0,0 +1,189 @@
+package lock
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A lock file whose holder never got to write its details is only
+// considered abandoned after this long.
+const incompleteLockAge = time.Minute
+
+// Lock is an exclusive lock on a story, held by creating a file that
+// records the pid and host of its owner.
+type Lock struct {
+	path string
+}
+
+// HeldError reports that another process owns the lock.
+type HeldError struct {
+	Path  string
+	PID   int
+	Host  string
+	Since time.Time
+}
+
+func (e *HeldError) Error() string {
+	holder := "another nora process"
+	if e.PID != 0 {
+		holder = fmt.Sprintf("nora process %d on %s", e.PID, e.Host)
+	}
+	return fmt.Sprintf("%s is held by %s since %s; wait for it to finish, or remove the file if that process is gone",
+		e.Path, holder, e.Since.Format("2006-01-02 15:04:05"))
+}
+
+// Acquire creates the lock file at path. A lock left behind by a process
+// on this host that is no longer running is taken over.
+//
+// The lock is written to a temporary file first and linked into place, so
+// it never exists without its details. Taking over a stale lock happens
+// under a second, short-lived guard file: only one process can replace a
+// given stale holder, and it re-reads the lock afterwards to confirm it
+// won.
+func Acquire(path string) (*Lock, error) {
+	host, _ := os.Hostname()
+	content := []byte(fmt.Sprintf("%d %s %d\n", os.Getpid(), host, time.Now().Unix()))
+	tmp, err := writeTemp(path, content)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp)
+
+	for attempt := 0; attempt < 10; attempt++ {
+		err := os.Link(tmp, path)
+		if err == nil {
+			return &Lock{path: path}, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to create %s: %w", path, err)
+		}
+
+		held, err := readHolder(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !held.stale(host) {
+			return nil, held
+		}
+
+		replaced, err := takeOver(path, tmp, held)
+		if err != nil {
+			return nil, err
+		}
+		if replaced && owned(path, content) {
+			return &Lock{path: path}, nil
+		}
+	}
+	return nil, fmt.Errorf("failed to acquire %s", path)
+}
+
+// takeOver replaces the stale lock held by held with the lock in tmp. It
+// reports false when another process got there first or is busy taking
+// over; the caller then looks at the lock again.
+func takeOver(path, tmp string, held *HeldError) (bool, error) {
+	guard := path + ".takeover"
+	if err := os.Link(tmp, guard); err != nil {
+		if !os.IsExist(err) {
+			return false, fmt.Errorf("failed to create %s: %w", guard, err)
+		}
+		// A guard is only held for a moment, so an old one was left by a
+		// process that died while taking over.
+		if info, err := os.Stat(guard); err == nil && time.Since(info.ModTime()) > incompleteLockAge {
+			os.Remove(guard)
+		} else {
+			time.Sleep(10 * time.Millisecond)
+		}
+		return false, nil
+	}
+	defer os.Remove(guard)
+
+	current, err := readHolder(path)
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	if err == nil && !current.same(held) {
+		return false, nil
+	}
+
+	replacement := fmt.Sprintf("%s.%d", guard, os.Getpid())
+	if err := os.Link(tmp, replacement); err != nil {
+		return false, fmt.Errorf("failed to create %s: %w", replacement, err)
+	}
+	if err := os.Rename(replacement, path); err != nil {
+		os.Remove(replacement)
+		return false, fmt.Errorf("failed to replace stale %s: %w", path, err)
+	}
+	return true, nil
+}
+
+func writeTemp(path string, content []byte) (string, error) {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	_, err = f.Write(content)
+	if err == nil {
+		err = f.Chmod(0644)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return f.Name(), nil
+}
+
+func owned(path string, content []byte) bool {
+	data, err := os.ReadFile(path)
+	return err == nil && bytes.Equal(data, content)
+}
+
+func (l *Lock) Release() error {
+	if err := os.Remove(l.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to release %s: %w", l.path, err)
+	}
+	return nil
+}
+
+func readHolder(path string) (*HeldError, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	held := &HeldError{Path: path, Since: info.ModTime()}
+	fields := strings.Fields(string(data))
+	if len(fields) == 3 {
+		held.PID, _ = strconv.Atoi(fields[0])
+		held.Host = fields[1]
+		if since, err := strconv.ParseInt(fields[2], 10, 64); err == nil {
+			held.Since = time.Unix(since, 0)
+		}
+	}
+	return held, nil
+}
+
+func (e *HeldError) same(other *HeldError) bool {
+	return e.PID == other.PID && e.Host == other.Host && e.Since.Equal(other.Since)
+}
+
+func (e *HeldError) stale(host string) bool {
+	if e.PID == 0 {
+		return time.Since(e.Since) > incompleteLockAge
+	}
+	return e.Host == host && !processAlive(e.PID)
+}
@@ -0,0 +1,84 @@
+package lock
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAcquireRefusesHeldLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lock")
+	held, err := Acquire(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = Acquire(path)
+	var heldErr *HeldError
+	if !errors.As(err, &heldErr) || heldErr.PID != os.Getpid() {
+		t.Fatalf("got %v, want a HeldError naming this process", err)
+	}
+
+	if err := held.Release(); err != nil {
+		t.Fatal(err)
+	}
+	again, err := Acquire(path)
+	if err != nil {
+		t.Fatalf("acquire after release: %v", err)
+	}
+	again.Release()
+}
+
+// TestStaleTakeoverHasOneWinner races many acquirers against a lock left
+// by a process that has exited; exactly one may take it over.
+func TestStaleTakeoverHasOneWinner(t *testing.T) {
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Skip("cannot start a process to leave a stale lock:", err)
+	}
+	host, _ := os.Hostname()
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(8))
+
+	for round := 0; round < 20; round++ {
+		path := filepath.Join(t.TempDir(), "lock")
+		stale := fmt.Sprintf("%d %s %d\n", cmd.Process.Pid, host, time.Now().Unix())
+		if err := os.WriteFile(path, []byte(stale), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		winners := 0
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := Acquire(path)
+				var heldErr *HeldError
+				switch {
+				case err == nil:
+					mu.Lock()
+					winners++
+					mu.Unlock()
+				case !errors.As(err, &heldErr):
+					t.Errorf("unexpected error: %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if winners != 1 {
+			t.Fatalf("round %d: %d acquirers took over the stale lock, want 1", round, winners)
+		}
+		matches, _ := filepath.Glob(path + ".*")
+		if len(matches) != 0 {
+			t.Fatalf("round %d: left behind %v", round, matches)
+		}
+	}
+}
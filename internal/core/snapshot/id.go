@@ -0,0 +1,88 @@
+package snapshot
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jolovicdev/nora/internal/core/storage"
+	"github.com/jolovicdev/nora/internal/types"
+)
+
+// MinPrefix is the shortest snapshot ID prefix accepted on the command
+// line.
+const MinPrefix = 4
+
+var ErrNotFound = errors.New("snapshot not found")
+
+// Canonical serialises the parts of a snapshot its ID is derived from, in
+// a fixed layout similar to a git commit:
+//
+//	tree <hash>
+//	parent <id>
+//	author <name> <<email>> <unix seconds> <+hhmm>
+//	committer <name> <<email>> <unix seconds> <+hhmm>
+//	timestamp <unix seconds>
+//
+//	<message>
+//
+// The parent line is left out for a root snapshot, and the author and
+// committer lines for snapshots made before signatures were recorded.
+func Canonical(snapshot *types.Snapshot) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "tree %s\n", snapshot.Tree)
+	if snapshot.Parent != "" {
+		fmt.Fprintf(&b, "parent %s\n", snapshot.Parent)
+	}
+	if snapshot.Author != nil {
+		fmt.Fprintf(&b, "author %s\n", canonicalSignature(snapshot.Author))
+	}
+	if snapshot.Committer != nil {
+		fmt.Fprintf(&b, "committer %s\n", canonicalSignature(snapshot.Committer))
+	}
+	fmt.Fprintf(&b, "timestamp %d\n", snapshot.Timestamp)
+	b.WriteString("\n")
+	b.WriteString(snapshot.Message)
+	return b.Bytes()
+}
+
+// ComputeID returns the content-addressed ID of a snapshot.
+func ComputeID(snapshot *types.Snapshot) string {
+	return storage.Hash(Canonical(snapshot))
+}
+
+// ContentAddressed reports whether an ID was derived from snapshot content;
+// older snapshots carry 12 character random IDs.
+func ContentAddressed(id string) bool {
+	return len(id) == 40
+}
+
+// Resolve expands a full snapshot ID or a unique prefix of one.
+func (s *Store) Resolve(prefix string) (string, error) {
+	ids, err := s.IDs()
+	if err != nil {
+		return "", err
+	}
+
+	var matches []string
+	for _, id := range ids {
+		if id == prefix {
+			return id, nil
+		}
+		if len(prefix) >= MinPrefix && strings.HasPrefix(id, prefix) {
+			matches = append(matches, id)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("%w: %s", ErrNotFound, prefix)
+	case 1:
+		return matches[0], nil
+	default:
+		sort.Strings(matches)
+		return "", fmt.Errorf("ambiguous snapshot prefix %s matches %s", prefix, strings.Join(matches, ", "))
+	}
+}
@@ -0,0 +1,194 @@
+package snapshot
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/jolovicdev/nora/internal/core/layout"
+	"github.com/jolovicdev/nora/internal/core/storage"
+	"github.com/jolovicdev/nora/internal/types"
+)
+
+func newStore(t *testing.T) *Store {
+	t.Helper()
+	l := layout.New(t.TempDir())
+	if err := os.MkdirAll(l.MemoriesDir(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return NewStore(l, storage.NewContentStore(l))
+}
+
+func sampleSnapshot() *types.Snapshot {
+	return &types.Snapshot{
+		Tree:      strings.Repeat("a", 40),
+		Parent:    strings.Repeat("b", 40),
+		Author:    &types.Signature{Name: "Ada Lovelace", Email: "ada@example.com", When: 1700000000, Offset: 60},
+		Committer: &types.Signature{Name: "Charles Babbage", Email: "cb@example.com", When: 1700000100, Offset: -300},
+		Timestamp: 1700000100,
+		Message:   "Add the engine\n",
+	}
+}
+
+func TestCanonical(t *testing.T) {
+	want := "tree " + strings.Repeat("a", 40) + "\n" +
+		"parent " + strings.Repeat("b", 40) + "\n" +
+		"author Ada Lovelace <ada@example.com> 1700000000 +0100\n" +
+		"committer Charles Babbage <cb@example.com> 1700000100 -0500\n" +
+		"timestamp 1700000100\n" +
+		"\n" +
+		"Add the engine\n"
+	if got := string(Canonical(sampleSnapshot())); got != want {
+		t.Errorf("Canonical =\n%s\nwant\n%s", got, want)
+	}
+
+	root := &types.Snapshot{Tree: strings.Repeat("c", 40), Timestamp: 5, Message: "first"}
+	want = "tree " + strings.Repeat("c", 40) + "\ntimestamp 5\n\nfirst"
+	if got := string(Canonical(root)); got != want {
+		t.Errorf("Canonical of a root snapshot without signatures =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestComputeIDChangesWithContent(t *testing.T) {
+	changes := map[string]func(*types.Snapshot){
+		"tree":          func(s *types.Snapshot) { s.Tree = strings.Repeat("d", 40) },
+		"parent":        func(s *types.Snapshot) { s.Parent = strings.Repeat("e", 40) },
+		"no parent":     func(s *types.Snapshot) { s.Parent = "" },
+		"author name":   func(s *types.Snapshot) { s.Author.Name = "Ada King" },
+		"author email":  func(s *types.Snapshot) { s.Author.Email = "ada@example.org" },
+		"author time":   func(s *types.Snapshot) { s.Author.When++ },
+		"author zone":   func(s *types.Snapshot) { s.Author.Offset = 0 },
+		"committer":     func(s *types.Snapshot) { s.Committer.Name = "Someone Else" },
+		"no signatures": func(s *types.Snapshot) { s.Author, s.Committer = nil, nil },
+		"timestamp":     func(s *types.Snapshot) { s.Timestamp++ },
+		"message":       func(s *types.Snapshot) { s.Message = "Add the engine" },
+	}
+
+	base := ComputeID(sampleSnapshot())
+	if len(base) != 40 || !ContentAddressed(base) {
+		t.Fatalf("ComputeID = %q, want a 40 character hash", base)
+	}
+	seen := map[string]string{base: "original"}
+	for name, change := range changes {
+		snapshot := sampleSnapshot()
+		change(snapshot)
+		id := ComputeID(snapshot)
+		if other, ok := seen[id]; ok {
+			t.Errorf("changing the %s gives the same ID as %s", name, other)
+		}
+		seen[id] = name
+	}
+
+	// Fields outside the canonical form do not affect the ID.
+	snapshot := sampleSnapshot()
+	snapshot.ID = "ignored"
+	snapshot.Files = map[string]string{"a.txt": strings.Repeat("f", 40)}
+	if id := ComputeID(snapshot); id != base {
+		t.Errorf("ID and Files changed the ID")
+	}
+}
+
+func TestBuildIDIsStableAcrossMapOrder(t *testing.T) {
+	s := newStore(t)
+	paths := make([]string, 50)
+	for i := range paths {
+		paths[i] = fmt.Sprintf("dir%d/file%d.txt", i%7, i)
+	}
+	author := &types.Signature{Name: "Ada", Email: "ada@example.com", When: 1700000000}
+
+	rng := rand.New(rand.NewSource(1))
+	var first *types.Snapshot
+	for round := 0; round < 20; round++ {
+		files := make(map[string]string)
+		modes := make(map[string]os.FileMode)
+		for _, i := range rng.Perm(len(paths)) {
+			hash, err := s.contentStore.Store([]byte(paths[i]))
+			if err != nil {
+				t.Fatal(err)
+			}
+			files[paths[i]] = hash
+			modes[paths[i]] = 0644
+		}
+		snapshot, err := s.Build("message", author, author, files, modes, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if first == nil {
+			first = snapshot
+			continue
+		}
+		if snapshot.Tree != first.Tree || snapshot.ID != first.ID {
+			t.Fatalf("round %d built tree %s id %s, first round %s %s", round, snapshot.Tree, snapshot.ID, first.Tree, first.ID)
+		}
+	}
+
+	if err := s.Save(first); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := s.Load(first.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id := ComputeID(loaded); id != first.ID {
+		t.Errorf("saved snapshot recomputes to %s, want %s", id, first.ID)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	s := newStore(t)
+	ids := []string{
+		"abcd1234" + strings.Repeat("0", 32),
+		"abcd5678" + strings.Repeat("0", 32),
+		"ef012345" + strings.Repeat("0", 32),
+		"a1b2c3d4e5f6", // random IDs of older versions
+	}
+	for _, id := range ids {
+		if err := s.Save(&types.Snapshot{ID: id, Message: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		prefix    string
+		want      string
+		notFound  bool
+		ambiguous bool
+	}{
+		{prefix: ids[0], want: ids[0]},
+		{prefix: "abcd1", want: ids[0]},
+		{prefix: "ef01", want: ids[2]},
+		{prefix: "a1b2c3d4e5f6", want: ids[3]},
+		{prefix: "a1b2", want: ids[3]},
+		{prefix: "abcd", ambiguous: true},
+		{prefix: "ef0", notFound: true},
+		{prefix: "e", notFound: true},
+		{prefix: "", notFound: true},
+		{prefix: "9999", notFound: true},
+		{prefix: ids[0] + "0", notFound: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.prefix, func(t *testing.T) {
+			got, err := s.Resolve(tt.prefix)
+			switch {
+			case tt.notFound:
+				if !errors.Is(err, ErrNotFound) {
+					t.Errorf("Resolve(%q) = %q, %v; want ErrNotFound", tt.prefix, got, err)
+				}
+			case tt.ambiguous:
+				if err == nil || errors.Is(err, ErrNotFound) || !strings.Contains(err.Error(), "ambiguous") {
+					t.Errorf("Resolve(%q) = %q, %v; want an ambiguity error", tt.prefix, got, err)
+				}
+				if err != nil && (!strings.Contains(err.Error(), ids[0]) || !strings.Contains(err.Error(), ids[1])) {
+					t.Errorf("ambiguity error does not list the candidates: %v", err)
+				}
+			default:
+				if err != nil || got != tt.want {
+					t.Errorf("Resolve(%q) = %q, %v; want %q", tt.prefix, got, err, tt.want)
+				}
+			}
+		})
+	}
+}
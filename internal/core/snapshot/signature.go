@@ -0,0 +1,64 @@
+package snapshot
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jolovicdev/nora/internal/types"
+)
+
+func NewSignature(name, email string, when time.Time) *types.Signature {
+	_, offset := when.Zone()
+	return &types.Signature{
+		Name:   strings.TrimSpace(identityReplacer.Replace(name)),
+		Email:  strings.TrimSpace(identityReplacer.Replace(email)),
+		When:   when.Unix(),
+		Offset: offset / 60,
+	}
+}
+
+// SignatureTime returns the signature's time in the zone it was made in.
+func SignatureTime(sig *types.Signature) time.Time {
+	return time.Unix(sig.When, 0).In(time.FixedZone("", sig.Offset*60))
+}
+
+// FormatIdentity renders a signature's name and email as "Name <email>".
+func FormatIdentity(sig *types.Signature) string {
+	if sig.Email == "" {
+		return sig.Name
+	}
+	return fmt.Sprintf("%s <%s>", sig.Name, sig.Email)
+}
+
+// ParseIdentity splits "Name <email>" into its parts.
+func ParseIdentity(value string) (string, string, error) {
+	value = strings.TrimSpace(value)
+	open := strings.LastIndexByte(value, '<')
+	if open <= 0 || !strings.HasSuffix(value, ">") {
+		return "", "", fmt.Errorf("invalid identity %q, expected \"Name <email>\"", value)
+	}
+	name := strings.TrimSpace(value[:open])
+	email := strings.TrimSpace(value[open+1 : len(value)-1])
+	if name == "" {
+		return "", "", fmt.Errorf("invalid identity %q, expected \"Name <email>\"", value)
+	}
+	return name, email, nil
+}
+
+func canonicalSignature(sig *types.Signature) string {
+	return fmt.Sprintf("%s <%s> %d %s", sig.Name, sig.Email, sig.When, formatOffset(sig.Offset))
+}
+
+func formatOffset(minutes int) string {
+	sign := "+"
+	if minutes < 0 {
+		sign = "-"
+		minutes = -minutes
+	}
+	return fmt.Sprintf("%s%02d%02d", sign, minutes/60, minutes%60)
+}
+
+// identityReplacer keeps names and emails from breaking the one-line
+// canonical form.
+var identityReplacer = strings.NewReplacer("\n", " ", "<", "", ">", "")
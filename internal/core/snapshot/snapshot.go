@@ -2,29 +2,30 @@ package snapshot
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
-	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/jolovicdev/nora/internal/core/layout"
+	"github.com/jolovicdev/nora/internal/core/storage"
 	"github.com/jolovicdev/nora/internal/types"
 	"github.com/jolovicdev/nora/internal/utils"
 )
 
 type Store struct {
-	rootPath string
+	layout       *layout.Layout
+	contentStore *storage.ContentStore
 }
 
-func NewStore(rootPath string) *Store {
-	return &Store{rootPath: rootPath}
+func NewStore(layout *layout.Layout, contentStore *storage.ContentStore) *Store {
+	return &Store{layout: layout, contentStore: contentStore}
 }
 
-func (s *Store) Create(message string, files map[string]string, parent string) (*types.Snapshot, error) {
-	snapshot := &types.Snapshot{
-		ID:        utils.GenerateID(),
-		Timestamp: time.Now().Unix(),
-		Message:   message,
-		Files:     files,
-		Parent:    parent,
+func (s *Store) Create(message string, author, committer *types.Signature, files map[string]string, modes map[string]os.FileMode, parent string) (*types.Snapshot, error) {
+	snapshot, err := s.Build(message, author, committer, files, modes, parent)
+	if err != nil {
+		return nil, err
 	}
 
 	if err := s.Save(snapshot); err != nil {
@@ -34,21 +35,133 @@ func (s *Store) Create(message string, files map[string]string, parent string) (
 	return snapshot, nil
 }
 
+// Build writes the tree for a new snapshot and returns the snapshot
+// without saving it. Its ID is derived from its content, and its
+// timestamp is the committer's time when there is a committer.
+func (s *Store) Build(message string, author, committer *types.Signature, files map[string]string, modes map[string]os.FileMode, parent string) (*types.Snapshot, error) {
+	tree, err := s.WriteTree(files, modes)
+	if err != nil {
+		return nil, err
+	}
+
+	timestamp := time.Now().Unix()
+	if committer != nil {
+		timestamp = committer.When
+	}
+	snapshot := &types.Snapshot{
+		Timestamp: timestamp,
+		Message:   message,
+		Tree:      tree,
+		Files:     files,
+		Parent:    parent,
+		Modes:     modes,
+		Author:    author,
+		Committer: committer,
+	}
+	snapshot.ID = ComputeID(snapshot)
+	return snapshot, nil
+}
+
 func (s *Store) Save(snapshot *types.Snapshot) error {
-	data, err := json.MarshalIndent(snapshot, "", "  ")
+	stored := *snapshot
+	if stored.Tree != "" {
+		stored.Files = nil
+		stored.Modes = nil
+	}
+
+	data, err := json.MarshalIndent(&stored, "", "  ")
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(filepath.Join(s.rootPath, "memories", snapshot.ID+".json"), data, 0644)
+	return utils.WriteFileAtomic(s.layout.Memory(snapshot.ID), data, 0644)
 }
 
 func (s *Store) Get(id string) (*types.Snapshot, error) {
-	data, err := os.ReadFile(filepath.Join(s.rootPath, "memories", id+".json"))
+	snapshot, err := s.Load(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if snapshot.Tree != "" {
+		snapshot.Files, snapshot.Modes, err = s.ReadTree(snapshot.Tree)
+		if err != nil {
+			return nil, err
+		}
+		return snapshot, nil
+	}
+
+	if err := s.resolveLegacy(snapshot); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+func (s *Store) IDs() ([]string, error) {
+	entries, err := os.ReadDir(s.layout.MemoriesDir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var ids []string
+	for _, entry := range entries {
+		if name := entry.Name(); !entry.IsDir() && strings.HasSuffix(name, ".json") {
+			ids = append(ids, strings.TrimSuffix(name, ".json"))
+		}
+	}
+	return ids, nil
+}
+
+// Load reads a snapshot as stored, without resolving its tree.
+func (s *Store) Load(id string) (*types.Snapshot, error) {
+	data, err := os.ReadFile(s.layout.Memory(id))
 	if err != nil {
 		return nil, err
 	}
 
 	var snapshot types.Snapshot
-	err = json.Unmarshal(data, &snapshot)
-	return &snapshot, err
-}
\ No newline at end of file
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot %s: %w", id, err)
+	}
+	return &snapshot, nil
+}
+
+// Snapshots written before trees were introduced only carry the files
+// prepared for them, so their full tree is rebuilt by replaying the
+// deltas on top of the nearest ancestor.
+func (s *Store) resolveLegacy(snapshot *types.Snapshot) error {
+	chain := []*types.Snapshot{snapshot}
+	seen := map[string]bool{snapshot.ID: true}
+	files := make(map[string]string)
+	modes := make(map[string]os.FileMode)
+
+	for parent := snapshot.Parent; parent != ""; {
+		if seen[parent] {
+			return fmt.Errorf("snapshot %s has a cyclic parent chain", snapshot.ID)
+		}
+		seen[parent] = true
+
+		ancestor, err := s.Load(parent)
+		if err != nil {
+			return fmt.Errorf("failed to load parent snapshot %s: %w", parent, err)
+		}
+		if ancestor.Tree != "" {
+			files, modes, err = s.ReadTree(ancestor.Tree)
+			if err != nil {
+				return err
+			}
+			break
+		}
+		chain = append(chain, ancestor)
+		parent = ancestor.Parent
+	}
+
+	for i := len(chain) - 1; i >= 0; i-- {
+		files, modes = MergeTree(files, modes, chain[i].Files, chain[i].Modes)
+	}
+	snapshot.Files = files
+	snapshot.Modes = modes
+	return nil
+}
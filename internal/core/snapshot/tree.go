@@ -0,0 +1,75 @@
+package snapshot
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/jolovicdev/nora/internal/core/storage"
+	"github.com/jolovicdev/nora/internal/types"
+)
+
+func (s *Store) WriteTree(files map[string]string, modes map[string]os.FileMode) (string, error) {
+	entries := make([]types.TreeEntry, 0, len(files))
+	for path, hash := range files {
+		entries = append(entries, types.TreeEntry{Path: path, Hash: hash, Mode: modes[path]})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Path < entries[j].Path
+	})
+
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal tree: %w", err)
+	}
+	return s.contentStore.StoreObject(storage.TreeObject, data)
+}
+
+func (s *Store) ReadTree(hash string) (map[string]string, map[string]os.FileMode, error) {
+	data, err := s.contentStore.Get(hash)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read tree %s: %w", hash, err)
+	}
+
+	var entries []types.TreeEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse tree %s: %w", hash, err)
+	}
+
+	files := make(map[string]string, len(entries))
+	modes := make(map[string]os.FileMode, len(entries))
+	for _, entry := range entries {
+		files[entry.Path] = entry.Hash
+		if entry.Mode != 0 {
+			modes[entry.Path] = entry.Mode
+		}
+	}
+	return files, modes, nil
+}
+
+func MergeTree(files map[string]string, modes map[string]os.FileMode, changes map[string]string, changedModes map[string]os.FileMode) (map[string]string, map[string]os.FileMode) {
+	mergedFiles := make(map[string]string, len(files)+len(changes))
+	mergedModes := make(map[string]os.FileMode, len(files)+len(changes))
+	for path, hash := range files {
+		mergedFiles[path] = hash
+		if mode, ok := modes[path]; ok {
+			mergedModes[path] = mode
+		}
+	}
+
+	for path, hash := range changes {
+		if hash == "" {
+			delete(mergedFiles, path)
+			delete(mergedModes, path)
+			continue
+		}
+		mergedFiles[path] = hash
+		if mode, ok := changedModes[path]; ok {
+			mergedModes[path] = mode
+		} else {
+			delete(mergedModes, path)
+		}
+	}
+	return mergedFiles, mergedModes
+}
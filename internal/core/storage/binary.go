@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	sniffLength = 8000
+	maxTextSize = 16 << 20
+)
+
+func IsBinary(content []byte) bool {
+	if len(content) > maxTextSize {
+		return true
+	}
+
+	sniff := content
+	if len(sniff) > sniffLength {
+		sniff = trimPartialRune(sniff[:sniffLength])
+	}
+
+	if bytes.IndexByte(sniff, 0) >= 0 {
+		return true
+	}
+	return !utf8.Valid(sniff)
+}
+
+func trimPartialRune(data []byte) []byte {
+	for i := 1; i < utf8.UTFMax && i <= len(data); i++ {
+		c := data[len(data)-i]
+		if c < utf8.RuneSelf {
+			break
+		}
+		if utf8.RuneStart(c) {
+			if !utf8.FullRune(data[len(data)-i:]) {
+				return data[:len(data)-i]
+			}
+			break
+		}
+	}
+	return data
+}
+
+type attributeRule struct {
+	pattern string
+	binary  bool
+}
+
+type Attributes struct {
+	rules []attributeRule
+}
+
+// LoadAttributes reads an attributes file where every line is a path
+// pattern followed by "binary", "text" or "-text". A missing file yields
+// an empty set of rules.
+func LoadAttributes(file string) (*Attributes, error) {
+	attrs := &Attributes{}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return attrs, nil
+		}
+		return nil, err
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		for _, attr := range fields[1:] {
+			switch attr {
+			case "binary", "-text":
+				attrs.rules = append(attrs.rules, attributeRule{pattern: fields[0], binary: true})
+			case "text":
+				attrs.rules = append(attrs.rules, attributeRule{pattern: fields[0], binary: false})
+			}
+		}
+	}
+	return attrs, nil
+}
+
+// IsBinary applies the last matching rule for the path and falls back to
+// sniffing the content when no rule matches.
+func (a *Attributes) IsBinary(file string, content []byte) bool {
+	for i := len(a.rules) - 1; i >= 0; i-- {
+		if matchAttribute(a.rules[i].pattern, file) {
+			return a.rules[i].binary
+		}
+	}
+	return IsBinary(content)
+}
+
+func matchAttribute(pattern, file string) bool {
+	pattern = strings.TrimPrefix(pattern, "/")
+	if strings.Contains(pattern, "/") {
+		matched, err := path.Match(pattern, file)
+		return err == nil && matched
+	}
+	matched, err := path.Match(pattern, path.Base(file))
+	return err == nil && matched
+}
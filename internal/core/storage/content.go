@@ -1,34 +1,80 @@
 package storage
 
 import (
+	"bytes"
+	"compress/zlib"
 	"crypto/sha1"
 	"encoding/hex"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
 
+	"github.com/jolovicdev/nora/internal/core/layout"
 	"github.com/jolovicdev/nora/internal/utils"
 )
 
+// Format versions a story's state records. Version 1 stores loose
+// objects zlib-compressed; version 2 adds packs, which older builds would
+// not look in, so a story moves to it when its first pack is written.
+const (
+	CompressedFormatVersion = 1
+	PackedFormatVersion     = 2
+
+	// FormatVersion is the newest format this build reads.
+	FormatVersion = PackedFormatVersion
+)
+
+const (
+	BlobObject = "blob"
+	TreeObject = "tree"
+)
+
+// ContentStore is safe for concurrent Store and Get calls; packing and
+// pruning expect to run alone.
 type ContentStore struct {
-	rootPath string
+	layout *layout.Layout
+	mu     sync.Mutex
+	packs  map[string]packLocation
 }
 
-func NewContentStore(rootPath string) *ContentStore {
-	return &ContentStore{rootPath: rootPath}
+// A zlib writer allocates about a megabyte of compressor state, which
+// dominates the cost of storing small files unless writers are reused.
+var zlibWriters = sync.Pool{
+	New: func() interface{} { return zlib.NewWriter(nil) },
 }
 
-func (cs *ContentStore) Store(content []byte) (string, error) {
+func NewContentStore(layout *layout.Layout) *ContentStore {
+	return &ContentStore{layout: layout}
+}
+
+func Hash(content []byte) string {
 	hash := sha1.Sum(content)
-	hashStr := hex.EncodeToString(hash[:])
-	
-	objPath := filepath.Join(cs.rootPath, "objects", hashStr[:2], hashStr[2:])
+	return hex.EncodeToString(hash[:])
+}
+
+func (cs *ContentStore) Store(content []byte) (string, error) {
+	return cs.StoreObject(BlobObject, content)
+}
+
+// StoreObject writes content zlib-compressed behind a "<kind> <length>\x00"
+// header. The object name stays the hash of the raw content so objects
+// keep their names when uncompressed repositories are migrated.
+func (cs *ContentStore) StoreObject(kind string, content []byte) (string, error) {
+	hashStr := Hash(content)
+
+	objPath := cs.objectPath(hashStr)
 	if err := utils.CreateDirIfNotExists(filepath.Dir(objPath)); err != nil {
 		return "", err
 	}
 
-	if _, err := os.Stat(objPath); os.IsNotExist(err) {
-		if err := os.WriteFile(objPath, content, 0644); err != nil {
+	if exists, err := cs.Has(hashStr); err != nil {
+		return "", err
+	} else if !exists {
+		if err := cs.writeObject(objPath, kind, content); err != nil {
 			return "", fmt.Errorf("failed to store content: %v", err)
 		}
 	}
@@ -37,6 +83,226 @@ func (cs *ContentStore) Store(content []byte) (string, error) {
 }
 
 func (cs *ContentStore) Get(hash string) ([]byte, error) {
-	objPath := filepath.Join(cs.rootPath, "objects", hash[:2], hash[2:])
-	return os.ReadFile(objPath)
-}
\ No newline at end of file
+	_, content, err := cs.GetObject(hash)
+	return content, err
+}
+
+func (cs *ContentStore) GetObject(hash string) (string, []byte, error) {
+	if !validHash(hash) {
+		return "", nil, fmt.Errorf("invalid object hash: %q", hash)
+	}
+
+	data, err := os.ReadFile(cs.objectPath(hash))
+	if os.IsNotExist(err) {
+		kind, content, found, packErr := cs.readPacked(hash)
+		if found || packErr != nil {
+			return kind, content, packErr
+		}
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	kind, content, err := decodeObject(data)
+	if err != nil {
+		if Hash(data) == hash {
+			return BlobObject, data, nil
+		}
+		return "", nil, fmt.Errorf("corrupt object %s: %w", hash, err)
+	}
+	return kind, content, nil
+}
+
+// Verify reads an object and checks that its content rehashes to its name.
+func (cs *ContentStore) Verify(hash string) error {
+	_, content, err := cs.GetObject(hash)
+	if err != nil {
+		return err
+	}
+	if actual := Hash(content); actual != hash {
+		return fmt.Errorf("content hashes to %s", actual)
+	}
+	return nil
+}
+
+func (cs *ContentStore) Has(hash string) (bool, error) {
+	if !validHash(hash) {
+		return false, nil
+	}
+	if _, err := os.Stat(cs.objectPath(hash)); err == nil {
+		return true, nil
+	} else if !os.IsNotExist(err) {
+		return false, err
+	}
+	if err := cs.loadPacks(); err != nil {
+		return false, err
+	}
+	_, packed := cs.packs[hash]
+	return packed, nil
+}
+
+// ObjectTime reports when an object was written: the modification time of
+// a loose object, or of the pack holding it.
+func (cs *ContentStore) ObjectTime(hash string) (time.Time, int64, error) {
+	if info, err := os.Stat(cs.objectPath(hash)); err == nil {
+		return info.ModTime(), info.Size(), nil
+	} else if !os.IsNotExist(err) {
+		return time.Time{}, 0, err
+	}
+
+	if err := cs.loadPacks(); err != nil {
+		return time.Time{}, 0, err
+	}
+	loc, ok := cs.packs[hash]
+	if !ok {
+		return time.Time{}, 0, os.ErrNotExist
+	}
+	info, err := os.Stat(loc.pack)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	return info.ModTime(), 0, nil
+}
+
+func (cs *ContentStore) RemoveLoose(hash string) error {
+	if !validHash(hash) {
+		return fmt.Errorf("invalid object hash: %q", hash)
+	}
+	if err := os.Remove(cs.objectPath(hash)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	os.Remove(filepath.Dir(cs.objectPath(hash)))
+	return nil
+}
+
+// Migrate rewrites loose objects stored by format version 0 as raw bytes
+// into the compressed format. kinds maps known hashes to their object
+// kind; anything else is migrated as a blob.
+func (cs *ContentStore) Migrate(kinds map[string]string) (int, error) {
+	migrated := 0
+	err := cs.walkLoose(func(hash, objPath string) error {
+		data, err := os.ReadFile(objPath)
+		if err != nil {
+			return err
+		}
+		if _, _, err := decodeObject(data); err == nil {
+			return nil
+		}
+		if Hash(data) != hash {
+			return fmt.Errorf("corrupt object %s", hash)
+		}
+
+		kind := kinds[hash]
+		if kind == "" {
+			kind = BlobObject
+		}
+		if err := cs.writeObject(objPath, kind, data); err != nil {
+			return fmt.Errorf("failed to rewrite object %s: %w", hash, err)
+		}
+		migrated++
+		return nil
+	})
+	return migrated, err
+}
+
+func (cs *ContentStore) walkLoose(fn func(hash, objPath string) error) error {
+	objectsDir := cs.layout.ObjectsDir()
+	dirs, err := os.ReadDir(objectsDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	for _, dir := range dirs {
+		if !dir.IsDir() || len(dir.Name()) != 2 {
+			continue
+		}
+		entries, err := os.ReadDir(filepath.Join(objectsDir, dir.Name()))
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			hash := dir.Name() + entry.Name()
+			if entry.IsDir() || !validHash(hash) {
+				continue
+			}
+			if err := fn(hash, filepath.Join(objectsDir, dir.Name(), entry.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (cs *ContentStore) objectPath(hash string) string {
+	return cs.layout.Object(hash)
+}
+
+func (cs *ContentStore) writeObject(objPath, kind string, content []byte) error {
+	var buf bytes.Buffer
+	zw := zlibWriters.Get().(*zlib.Writer)
+	defer zlibWriters.Put(zw)
+	zw.Reset(&buf)
+	fmt.Fprintf(zw, "%s %d\x00", kind, len(content))
+	if _, err := zw.Write(content); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	// Concurrent writers of the same object each fill their own temporary
+	// file, so readers never see a partially written object.
+	return utils.WriteFileAtomic(objPath, buf.Bytes(), 0644)
+}
+
+func decodeObject(data []byte) (string, []byte, error) {
+	zr, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return "", nil, err
+	}
+	defer zr.Close()
+
+	raw, err := io.ReadAll(zr)
+	if err != nil {
+		return "", nil, err
+	}
+
+	nul := bytes.IndexByte(raw, 0)
+	if nul < 0 {
+		return "", nil, fmt.Errorf("missing object header")
+	}
+	kind, length, err := parseObjectHeader(raw[:nul])
+	if err != nil {
+		return "", nil, err
+	}
+
+	content := raw[nul+1:]
+	if len(content) != length {
+		return "", nil, fmt.Errorf("object length mismatch: header says %d, got %d", length, len(content))
+	}
+	return kind, content, nil
+}
+
+// parseObjectHeader parses "<kind> <length>", the header without its NUL.
+func parseObjectHeader(header []byte) (string, int, error) {
+	kind, size, ok := bytes.Cut(header, []byte(" "))
+	if !ok {
+		return "", 0, fmt.Errorf("malformed object header")
+	}
+	length, err := strconv.Atoi(string(size))
+	if err != nil {
+		return "", 0, fmt.Errorf("malformed object length: %w", err)
+	}
+	return string(kind), length, nil
+}
+
+func validHash(hash string) bool {
+	if len(hash) != 40 {
+		return false
+	}
+	_, err := hex.DecodeString(hash)
+	return err == nil
+}
@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jolovicdev/nora/internal/core/layout"
+)
+
+func newStore(t *testing.T) *ContentStore {
+	t.Helper()
+	return NewContentStore(layout.New(t.TempDir()))
+}
+
+// writeRaw stores content the way format version 0 did: uncompressed and
+// without a header.
+func writeRaw(t *testing.T, cs *ContentStore, content []byte) string {
+	t.Helper()
+	hash := Hash(content)
+	path := cs.objectPath(hash)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+func TestStoreObjectRoundTrip(t *testing.T) {
+	cs := newStore(t)
+	tests := []struct {
+		kind    string
+		content string
+	}{
+		{BlobObject, "hello\n"},
+		{BlobObject, ""},
+		{TreeObject, "100644 a.txt\x00hash\n"},
+	}
+	for _, tt := range tests {
+		hash, err := cs.StoreObject(tt.kind, []byte(tt.content))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hash != Hash([]byte(tt.content)) {
+			t.Errorf("%q stored as %s, want the hash of its content", tt.content, hash)
+		}
+
+		kind, content, err := cs.GetObject(hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if kind != tt.kind || string(content) != tt.content {
+			t.Errorf("read back %s %q, want %s %q", kind, content, tt.kind, tt.content)
+		}
+
+		onDisk, err := os.ReadFile(cs.objectPath(hash))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := decodeObject(onDisk); err != nil {
+			t.Errorf("%s is not stored compressed: %v", hash, err)
+		}
+	}
+}
+
+func TestGetObjectReadsRawObjects(t *testing.T) {
+	cs := newStore(t)
+	hash := writeRaw(t, cs, []byte("written by an old version\n"))
+
+	kind, content, err := cs.GetObject(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kind != BlobObject || string(content) != "written by an old version\n" {
+		t.Errorf("read %s %q", kind, content)
+	}
+}
+
+func TestGetObjectRejectsCorruptObjects(t *testing.T) {
+	cs := newStore(t)
+	hash := writeRaw(t, cs, []byte("original"))
+	if err := os.WriteFile(cs.objectPath(hash), []byte("tampered"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := cs.GetObject(hash); err == nil {
+		t.Error("read a corrupt object without error")
+	}
+	if _, _, err := cs.GetObject("not-a-hash"); err == nil {
+		t.Error("accepted an invalid hash")
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	cs := newStore(t)
+	blob := writeRaw(t, cs, []byte("blob content\n"))
+	tree := writeRaw(t, cs, []byte("100644 a.txt\x00"+blob+"\n"))
+	compressed, err := cs.Store([]byte("already compressed\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	migrated, err := cs.Migrate(map[string]string{tree: TreeObject})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if migrated != 2 {
+		t.Errorf("migrated %d objects, want 2", migrated)
+	}
+
+	for hash, want := range map[string]string{blob: BlobObject, tree: TreeObject, compressed: BlobObject} {
+		onDisk, err := os.ReadFile(cs.objectPath(hash))
+		if err != nil {
+			t.Fatal(err)
+		}
+		kind, _, err := decodeObject(onDisk)
+		if err != nil {
+			t.Errorf("%s is not compressed after migrating: %v", hash, err)
+		} else if kind != want {
+			t.Errorf("%s migrated as %s, want %s", hash, kind, want)
+		}
+		if err := cs.Verify(hash); err != nil {
+			t.Errorf("%s: %v", hash, err)
+		}
+	}
+
+	if migrated, err := cs.Migrate(nil); err != nil || migrated != 0 {
+		t.Errorf("second migrate: %d objects, %v; want 0, nil", migrated, err)
+	}
+}
+
+func TestMigrateRejectsCorruptObjects(t *testing.T) {
+	cs := newStore(t)
+	hash := writeRaw(t, cs, []byte("original"))
+	if err := os.WriteFile(cs.objectPath(hash), []byte("tampered"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cs.Migrate(nil); err == nil {
+		t.Error("migrated a corrupt object without error")
+	}
+}
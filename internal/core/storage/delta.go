@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	deltaBlock   = 16
+	maxCopySize  = 0xffffff
+	maxInsertRun = 0x7f
+)
+
+// Deltas use the copy/insert instruction stream git uses for packs: the
+// base and result sizes as uvarints, then instructions that either copy a
+// range of the base (high bit set) or insert up to 127 literal bytes.
+func MakeDelta(base, target []byte) []byte {
+	delta := binary.AppendUvarint(nil, uint64(len(base)))
+	delta = binary.AppendUvarint(delta, uint64(len(target)))
+
+	blocks := make(map[uint64]int)
+	for i := 0; i+deltaBlock <= len(base); i += deltaBlock {
+		key := blockHash(base[i : i+deltaBlock])
+		if _, ok := blocks[key]; !ok {
+			blocks[key] = i
+		}
+	}
+
+	var literal []byte
+	flush := func() {
+		for len(literal) > 0 {
+			n := len(literal)
+			if n > maxInsertRun {
+				n = maxInsertRun
+			}
+			delta = append(delta, byte(n))
+			delta = append(delta, literal[:n]...)
+			literal = literal[n:]
+		}
+	}
+
+	for i := 0; i < len(target); {
+		if i+deltaBlock <= len(target) {
+			if offset, ok := blocks[blockHash(target[i:i+deltaBlock])]; ok {
+				length := 0
+				for offset+length < len(base) && i+length < len(target) && base[offset+length] == target[i+length] && length < maxCopySize {
+					length++
+				}
+				if length >= deltaBlock {
+					flush()
+					delta = appendCopy(delta, offset, length)
+					i += length
+					continue
+				}
+			}
+		}
+		literal = append(literal, target[i])
+		i++
+	}
+	flush()
+	return delta
+}
+
+func ApplyDelta(base, delta []byte) ([]byte, error) {
+	baseSize, n := binary.Uvarint(delta)
+	if n <= 0 || baseSize != uint64(len(base)) {
+		return nil, fmt.Errorf("delta base size mismatch")
+	}
+	delta = delta[n:]
+	resultSize, n := binary.Uvarint(delta)
+	if n <= 0 {
+		return nil, fmt.Errorf("invalid delta result size")
+	}
+	delta = delta[n:]
+
+	// A corrupt size must not make us allocate more than the delta could
+	// plausibly produce.
+	result := make([]byte, 0, min(resultSize, uint64(len(base)+len(delta))))
+	for len(delta) > 0 {
+		if uint64(len(result)) > resultSize {
+			return nil, fmt.Errorf("delta result size mismatch")
+		}
+		op := delta[0]
+		delta = delta[1:]
+
+		if op&0x80 == 0 {
+			if op == 0 || int(op) > len(delta) {
+				return nil, fmt.Errorf("invalid delta insert")
+			}
+			result = append(result, delta[:op]...)
+			delta = delta[op:]
+			continue
+		}
+
+		var offset, size int
+		for i := 0; i < 4; i++ {
+			if op&(1<<i) != 0 {
+				if len(delta) == 0 {
+					return nil, fmt.Errorf("truncated delta copy")
+				}
+				offset |= int(delta[0]) << (8 * i)
+				delta = delta[1:]
+			}
+		}
+		for i := 0; i < 3; i++ {
+			if op&(1<<(4+i)) != 0 {
+				if len(delta) == 0 {
+					return nil, fmt.Errorf("truncated delta copy")
+				}
+				size |= int(delta[0]) << (8 * i)
+				delta = delta[1:]
+			}
+		}
+		if size == 0 {
+			size = 0x10000
+		}
+		if offset+size > len(base) {
+			return nil, fmt.Errorf("delta copy out of range")
+		}
+		result = append(result, base[offset:offset+size]...)
+	}
+
+	if uint64(len(result)) != resultSize {
+		return nil, fmt.Errorf("delta result size mismatch")
+	}
+	return result, nil
+}
+
+func appendCopy(delta []byte, offset, length int) []byte {
+	op := byte(0x80)
+	var args []byte
+	for i := 0; i < 4; i++ {
+		if b := byte(offset >> (8 * i)); b != 0 {
+			op |= 1 << i
+			args = append(args, b)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		if b := byte(length >> (8 * i)); b != 0 {
+			op |= 1 << (4 + i)
+			args = append(args, b)
+		}
+	}
+	return append(append(delta, op), args...)
+}
+
+// blockHash is FNV-1a computed inline; it runs at every offset of the
+// target, where allocating a hash.Hash64 each time dominated delta cost.
+func blockHash(block []byte) uint64 {
+	h := uint64(14695981039346656037)
+	for _, b := range block {
+		h ^= uint64(b)
+		h *= 1099511628211
+	}
+	return h
+}
@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func randomBytes(rng *rand.Rand, n int) []byte {
+	data := make([]byte, n)
+	rng.Read(data)
+	return data
+}
+
+func TestDeltaRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	text := []byte(strings.Repeat("the quick brown fox jumps over the lazy dog\n", 200))
+	large := randomBytes(rng, 300000)
+	edited := append(append(append([]byte(nil), text[:3000]...), "inserted line\n"...), text[3000:]...)
+
+	tests := []struct {
+		name         string
+		base, target []byte
+	}{
+		{"both empty", nil, nil},
+		{"empty base", nil, text},
+		{"empty target", text, nil},
+		{"identical", text, text},
+		{"insert in the middle", text, edited},
+		{"delete from the middle", edited, text},
+		{"unrelated", text, randomBytes(rng, 5000)},
+		{"literal runs longer than one insert", []byte("short"), randomBytes(rng, 1000)},
+		{"copies longer than 64k", large, append(append([]byte("head"), large...), "tail"...)},
+		{"shorter than a block", []byte("abc"), []byte("abd")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delta := MakeDelta(tt.base, tt.target)
+			got, err := ApplyDelta(tt.base, delta)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, tt.target) {
+				t.Fatalf("round trip gave %d bytes, want %d", len(got), len(tt.target))
+			}
+		})
+	}
+
+	if delta := MakeDelta(text, edited); len(delta) > 100 {
+		t.Errorf("delta for a one-line insert is %d bytes", len(delta))
+	}
+}
+
+func TestApplyDeltaRejectsCorruptDeltas(t *testing.T) {
+	base := []byte(strings.Repeat("0123456789abcdef", 4))
+	header := func(baseSize, resultSize uint64) []byte {
+		return binary.AppendUvarint(binary.AppendUvarint(nil, baseSize), resultSize)
+	}
+
+	tests := []struct {
+		name  string
+		delta []byte
+	}{
+		{"empty", nil},
+		{"wrong base size", append(header(10, 3), 3, 'a', 'b', 'c')},
+		{"missing result size", binary.AppendUvarint(nil, uint64(len(base)))},
+		{"insert past the end", append(header(64, 5), 5, 'a', 'b')},
+		{"zero insert", append(header(64, 0), 0)},
+		{"truncated copy", append(header(64, 16), 0x80|0x01|0x10)},
+		{"copy out of range", append(header(64, 16), 0x80|0x01|0x10, 60, 16)},
+		{"result too short", append(header(64, 10), 3, 'a', 'b', 'c')},
+		{"result too long", append(header(64, 1), 3, 'a', 'b', 'c')},
+		{"huge result size", append(header(64, 1<<62), 0x80|0x10, 64)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ApplyDelta(base, tt.delta); err == nil {
+				t.Error("applied a corrupt delta without error")
+			}
+		})
+	}
+}
+
+func BenchmarkMakeDelta(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	base := randomBytes(rng, 1<<20)
+	target := append(append([]byte(nil), base[:1<<19]...), randomBytes(rng, 100)...)
+	target = append(target, base[1<<19:]...)
+	b.SetBytes(int64(len(target)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		MakeDelta(base, target)
+	}
+}
@@ -4,15 +4,17 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
-	"path/filepath"
+
+	"github.com/jolovicdev/nora/internal/core/layout"
+	"github.com/jolovicdev/nora/internal/utils"
 )
 
 type Index struct {
-	rootPath string
+	layout *layout.Layout
 }
 
-func NewIndex(rootPath string) *Index {
-	return &Index{rootPath: rootPath}
+func NewIndex(layout *layout.Layout) *Index {
+	return &Index{layout: layout}
 }
 
 func (idx *Index) PrepareFiles(files map[string]string) error {
@@ -20,45 +22,54 @@ func (idx *Index) PrepareFiles(files map[string]string) error {
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(filepath.Join(idx.rootPath, "index", "prepared.json"), data, 0644)
+	return utils.WriteFileAtomic(idx.layout.PreparedFiles(), data, 0644)
 }
 
 func (idx *Index) ForgetFiles(paths []string) error {
-    prepared := make(map[string]string)
-    
-    data, err := os.ReadFile(filepath.Join(idx.rootPath, "index", "prepared.json"))
-    if err != nil && !os.IsNotExist(err) {
-        return fmt.Errorf("failed to read prepared.json: %w", err)
-    }
-        if len(data) > 0 {
-        if err := json.Unmarshal(data, &prepared); err != nil {
-            return fmt.Errorf("failed to unmarshal prepared files: %w", err)
-        }
-    }
-        for _, path := range paths {
-        delete(prepared, path)
-    }
-    
-    updatedData, err := json.Marshal(prepared)
-    if err != nil {
-        return fmt.Errorf("failed to marshal updated prepared files: %w", err)
-    }
-    indexDir := filepath.Join(idx.rootPath, "index")
-    if err := os.MkdirAll(indexDir, 0755); err != nil {
-        return fmt.Errorf("failed to create index directory: %w", err)
-    }
-    
+	prepared := make(map[string]string)
+
+	data, err := os.ReadFile(idx.layout.PreparedFiles())
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read prepared.json: %w", err)
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &prepared); err != nil {
+			return fmt.Errorf("failed to unmarshal prepared files: %w", err)
+		}
+	}
+	for _, path := range paths {
+		delete(prepared, path)
+	}
+
+	modes, err := idx.GetPreparedModes()
+	if err != nil {
+		return fmt.Errorf("failed to read prepared modes: %w", err)
+	}
+	for _, path := range paths {
+		delete(modes, path)
+	}
+	if err := idx.PrepareModes(modes); err != nil {
+		return fmt.Errorf("failed to write prepared modes: %w", err)
+	}
+
+	updatedData, err := json.Marshal(prepared)
+	if err != nil {
+		return fmt.Errorf("failed to marshal updated prepared files: %w", err)
+	}
+	if err := os.MkdirAll(idx.layout.IndexDir(), 0755); err != nil {
+		return fmt.Errorf("failed to create index directory: %w", err)
+	}
+
+	if err := utils.WriteFileAtomic(idx.layout.PreparedFiles(), updatedData, 0644); err != nil {
+		return fmt.Errorf("failed to write prepared.json: %w", err)
+	}
 
-    if err := os.WriteFile(filepath.Join(indexDir, "prepared.json"), updatedData, 0644); err != nil {
-        return fmt.Errorf("failed to write prepared.json: %w", err)
-    }
-    
-    return nil
+	return nil
 }
 
 func (idx *Index) GetPreparedFiles() (map[string]string, error) {
 	prepared := make(map[string]string)
-	data, err := os.ReadFile(filepath.Join(idx.rootPath, "index", "prepared.json"))
+	data, err := os.ReadFile(idx.layout.PreparedFiles())
 	if err != nil {
 		if os.IsNotExist(err) {
 			return prepared, nil
@@ -68,3 +79,24 @@ func (idx *Index) GetPreparedFiles() (map[string]string, error) {
 	err = json.Unmarshal(data, &prepared)
 	return prepared, err
 }
+
+func (idx *Index) PrepareModes(modes map[string]os.FileMode) error {
+	data, err := json.MarshalIndent(modes, "", "  ")
+	if err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(idx.layout.PreparedModes(), data, 0644)
+}
+
+func (idx *Index) GetPreparedModes() (map[string]os.FileMode, error) {
+	modes := make(map[string]os.FileMode)
+	data, err := os.ReadFile(idx.layout.PreparedModes())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return modes, nil
+		}
+		return nil, err
+	}
+	err = json.Unmarshal(data, &modes)
+	return modes, err
+}
@@ -0,0 +1,503 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jolovicdev/nora/internal/utils"
+)
+
+// Packs live in objects/pack as pack-<name>.pack with a sorted
+// pack-<name>.idx next to it. A pack is a header ("NPCK", version, object
+// count), one entry per object and a trailing SHA-1 of everything before
+// it. Each entry is: entry type (full or delta), kind length and kind,
+// the base hash for deltas, the compressed length and the zlib data.
+const (
+	packMagic   = "NPCK"
+	indexMagic  = "NIDX"
+	packVersion = 1
+
+	packEntryFull  = 0
+	packEntryDelta = 1
+
+	deltaWindow   = 10
+	maxDeltaDepth = 50
+)
+
+type packLocation struct {
+	pack   string
+	offset int64
+}
+
+type PackStats struct {
+	Objects     int
+	Deltas      int
+	LooseBytes  int64
+	PackedBytes int64
+}
+
+// packObject is what writePack keeps for every object. Contents are only
+// held for the objects in the delta window, so packing does not need
+// memory in proportion to the whole story.
+type packObject struct {
+	hash  string
+	kind  string
+	size  int64
+	depth int
+}
+
+func (cs *ContentStore) loadPacks() error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if cs.packs != nil {
+		return nil
+	}
+
+	packs := make(map[string]packLocation)
+	indexes, err := filepath.Glob(filepath.Join(cs.layout.PackDir(), "pack-*.idx"))
+	if err != nil {
+		return err
+	}
+	for _, idx := range indexes {
+		entries, err := readPackIndex(idx)
+		if err != nil {
+			return fmt.Errorf("failed to read pack index %s: %w", filepath.Base(idx), err)
+		}
+		packPath := strings.TrimSuffix(idx, ".idx") + ".pack"
+		for hash, offset := range entries {
+			packs[hash] = packLocation{pack: packPath, offset: offset}
+		}
+	}
+	cs.packs = packs
+	return nil
+}
+
+func (cs *ContentStore) PackedHashes() ([]string, error) {
+	if err := cs.loadPacks(); err != nil {
+		return nil, err
+	}
+	hashes := make([]string, 0, len(cs.packs))
+	for hash := range cs.packs {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+	return hashes, nil
+}
+
+// VerifyPacks checks the trailing checksum of every pack file.
+func (cs *ContentStore) VerifyPacks() map[string]error {
+	problems := make(map[string]error)
+	packs, err := filepath.Glob(filepath.Join(cs.layout.PackDir(), "pack-*.pack"))
+	if err != nil {
+		problems[cs.layout.PackDir()] = err
+		return problems
+	}
+	for _, pack := range packs {
+		data, err := os.ReadFile(pack)
+		if err != nil {
+			problems[filepath.Base(pack)] = err
+			continue
+		}
+		if len(data) < 12+sha1.Size || string(data[:4]) != packMagic {
+			problems[filepath.Base(pack)] = fmt.Errorf("not a pack file")
+			continue
+		}
+		body, trailer := data[:len(data)-sha1.Size], data[len(data)-sha1.Size:]
+		if sum := sha1.Sum(body); !bytes.Equal(sum[:], trailer) {
+			problems[filepath.Base(pack)] = fmt.Errorf("pack checksum mismatch")
+		}
+	}
+	return problems
+}
+
+func (cs *ContentStore) LooseHashes() ([]string, error) {
+	var hashes []string
+	err := cs.walkLoose(func(hash, _ string) error {
+		hashes = append(hashes, hash)
+		return nil
+	})
+	return hashes, err
+}
+
+func (cs *ContentStore) readPacked(hash string) (string, []byte, bool, error) {
+	return cs.readPackedChain(hash, 0)
+}
+
+// readPackedChain reads a packed object that is depth deltas away from
+// the object first asked for, refusing chains longer than any writePack
+// produces so a corrupt pack with a cycle cannot recurse forever.
+func (cs *ContentStore) readPackedChain(hash string, depth int) (string, []byte, bool, error) {
+	if err := cs.loadPacks(); err != nil {
+		return "", nil, false, err
+	}
+	loc, ok := cs.packs[hash]
+	if !ok {
+		return "", nil, false, nil
+	}
+
+	f, err := os.Open(loc.pack)
+	if err != nil {
+		return "", nil, true, err
+	}
+	defer f.Close()
+	if _, err := f.Seek(loc.offset, io.SeekStart); err != nil {
+		return "", nil, true, err
+	}
+
+	r := bufio.NewReader(f)
+	var header [2]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return "", nil, true, fmt.Errorf("truncated pack entry for %s: %w", hash, err)
+	}
+	kind := make([]byte, header[1])
+	if _, err := io.ReadFull(r, kind); err != nil {
+		return "", nil, true, fmt.Errorf("truncated pack entry for %s: %w", hash, err)
+	}
+
+	var base string
+	if header[0] == packEntryDelta {
+		var raw [20]byte
+		if _, err := io.ReadFull(r, raw[:]); err != nil {
+			return "", nil, true, fmt.Errorf("truncated pack entry for %s: %w", hash, err)
+		}
+		base = hex.EncodeToString(raw[:])
+	}
+
+	var length uint32
+	if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+		return "", nil, true, fmt.Errorf("truncated pack entry for %s: %w", hash, err)
+	}
+	zr, err := zlib.NewReader(io.LimitReader(r, int64(length)))
+	if err != nil {
+		return "", nil, true, fmt.Errorf("corrupt pack entry for %s: %w", hash, err)
+	}
+	data, err := io.ReadAll(zr)
+	zr.Close()
+	if err != nil {
+		return "", nil, true, fmt.Errorf("corrupt pack entry for %s: %w", hash, err)
+	}
+
+	if header[0] != packEntryDelta {
+		return string(kind), data, true, nil
+	}
+
+	if depth >= maxDeltaDepth {
+		return "", nil, true, fmt.Errorf("delta chain for %s is longer than %d", hash, maxDeltaDepth)
+	}
+	_, baseContent, found, err := cs.readPackedChain(base, depth+1)
+	if err == nil && !found {
+		_, baseContent, err = cs.GetObject(base)
+	}
+	if err != nil {
+		return "", nil, true, fmt.Errorf("failed to read delta base %s for %s: %w", base, hash, err)
+	}
+	content, err := ApplyDelta(baseContent, data)
+	if err != nil {
+		return "", nil, true, fmt.Errorf("failed to apply delta for %s: %w", hash, err)
+	}
+	return string(kind), content, true, nil
+}
+
+// Pack bundles loose objects into a new pack, storing objects as deltas
+// against similar objects of the same kind when that saves at least half
+// of their size. With all set, existing packs are folded into the new one.
+func (cs *ContentStore) Pack(all bool) (PackStats, error) {
+	var stats PackStats
+
+	hashes, err := cs.LooseHashes()
+	if err != nil {
+		return stats, err
+	}
+	if all {
+		packed, err := cs.PackedHashes()
+		if err != nil {
+			return stats, err
+		}
+		hashes = append(hashes, packed...)
+	}
+	return cs.writePack(dedupe(hashes), all)
+}
+
+// Repack rewrites all packed and loose objects into a single pack that
+// contains exactly the given hashes; everything else is dropped.
+func (cs *ContentStore) Repack(keep []string) (PackStats, error) {
+	return cs.writePack(dedupe(keep), true)
+}
+
+func (cs *ContentStore) writePack(hashes []string, replaceOld bool) (PackStats, error) {
+	var stats PackStats
+	if len(hashes) == 0 {
+		if replaceOld {
+			return stats, cs.removePacks("")
+		}
+		return stats, nil
+	}
+
+	objects := make([]*packObject, 0, len(hashes))
+	for _, hash := range hashes {
+		kind, size, err := cs.objectHeader(hash)
+		if err != nil {
+			return stats, fmt.Errorf("failed to read object %s: %w", hash, err)
+		}
+		if info, err := os.Stat(cs.objectPath(hash)); err == nil {
+			stats.LooseBytes += info.Size()
+		}
+		objects = append(objects, &packObject{hash: hash, kind: kind, size: size})
+	}
+
+	sort.SliceStable(objects, func(i, j int) bool {
+		if objects[i].kind != objects[j].kind {
+			return objects[i].kind < objects[j].kind
+		}
+		return objects[i].size > objects[j].size
+	})
+
+	name, err := cs.writePackFiles(objects, &stats)
+	if err != nil {
+		return stats, err
+	}
+	stats.Objects = len(objects)
+
+	for _, obj := range objects {
+		if err := os.Remove(cs.objectPath(obj.hash)); err != nil && !os.IsNotExist(err) {
+			return stats, fmt.Errorf("failed to remove loose object %s: %w", obj.hash, err)
+		}
+		os.Remove(filepath.Dir(cs.objectPath(obj.hash)))
+	}
+	if replaceOld {
+		if err := cs.removePacks(name); err != nil {
+			return stats, err
+		}
+	}
+
+	cs.packs = nil
+	return stats, nil
+}
+
+// objectHeader returns the kind and size of an object. Loose objects
+// only have their header decompressed; packed ones are read whole.
+func (cs *ContentStore) objectHeader(hash string) (string, int64, error) {
+	f, err := os.Open(cs.objectPath(hash))
+	if os.IsNotExist(err) {
+		kind, content, err := cs.GetObject(hash)
+		return kind, int64(len(content)), err
+	}
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	if zr, err := zlib.NewReader(bufio.NewReader(f)); err == nil {
+		defer zr.Close()
+		header, err := bufio.NewReader(zr).ReadBytes(0)
+		if err == nil {
+			if kind, length, err := parseObjectHeader(header[:len(header)-1]); err == nil {
+				return kind, int64(length), nil
+			}
+		}
+	}
+	// Format version 0 stored blobs raw.
+	info, err := f.Stat()
+	if err != nil {
+		return "", 0, err
+	}
+	return BlobObject, info.Size(), nil
+}
+
+// removePacks deletes every pack except the one named keep.
+func (cs *ContentStore) removePacks(keep string) error {
+	oldPacks, err := filepath.Glob(filepath.Join(cs.layout.PackDir(), "pack-*"))
+	if err != nil {
+		return err
+	}
+	for _, old := range oldPacks {
+		if strings.TrimSuffix(strings.TrimSuffix(filepath.Base(old), ".pack"), ".idx") != "pack-"+keep {
+			if err := os.Remove(old); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to remove old pack %s: %w", filepath.Base(old), err)
+			}
+		}
+	}
+	cs.packs = nil
+	return nil
+}
+
+// windowEntry is an object recently written to a pack, kept as a
+// candidate delta base for the objects after it.
+type windowEntry struct {
+	obj     *packObject
+	content []byte
+}
+
+// writePackFiles streams objects into a new pack, deltifying each against
+// the deltaWindow objects before it, then writes the index. It returns the
+// pack's name.
+func (cs *ContentStore) writePackFiles(objects []*packObject, stats *PackStats) (string, error) {
+	if err := os.MkdirAll(cs.layout.PackDir(), 0755); err != nil {
+		return "", err
+	}
+
+	names := make([]string, len(objects))
+	for i, obj := range objects {
+		names[i] = obj.hash
+	}
+	sort.Strings(names)
+	name := Hash([]byte(strings.Join(names, "\n")))
+	base := filepath.Join(cs.layout.PackDir(), "pack-"+name)
+
+	tmp, err := os.CreateTemp(cs.layout.PackDir(), ".pack-*.tmp")
+	if err != nil {
+		return "", fmt.Errorf("failed to write pack: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	checksum := sha1.New()
+	pack := bufio.NewWriter(io.MultiWriter(tmp, checksum))
+	var offset int64
+	write := func(data []byte) {
+		pack.Write(data)
+		offset += int64(len(data))
+	}
+
+	var header [12]byte
+	copy(header[:], packMagic)
+	binary.BigEndian.PutUint32(header[4:], packVersion)
+	binary.BigEndian.PutUint32(header[8:], uint32(len(objects)))
+	write(header[:])
+
+	offsets := make(map[string]int64, len(objects))
+	var window []windowEntry
+	var compressed bytes.Buffer
+	for _, obj := range objects {
+		kind, content, err := cs.GetObject(obj.hash)
+		if err != nil {
+			return "", fmt.Errorf("failed to read object %s: %w", obj.hash, err)
+		}
+
+		var baseHash string
+		var delta []byte
+		for i := len(window) - 1; i >= 0; i-- {
+			candidate := window[i]
+			if candidate.obj.kind != kind || candidate.obj.depth >= maxDeltaDepth || len(candidate.content) == 0 {
+				continue
+			}
+			d := MakeDelta(candidate.content, content)
+			if len(d) < len(content)/2 && (delta == nil || len(d) < len(delta)) {
+				baseHash, delta, obj.depth = candidate.obj.hash, d, candidate.obj.depth+1
+			}
+		}
+
+		offsets[obj.hash] = offset
+		entry := []byte{packEntryFull, byte(len(kind))}
+		entry = append(entry, kind...)
+		data := content
+		if delta != nil {
+			entry[0] = packEntryDelta
+			raw, _ := hex.DecodeString(baseHash)
+			entry = append(entry, raw...)
+			data = delta
+			stats.Deltas++
+		}
+
+		compressed.Reset()
+		zw := zlibWriters.Get().(*zlib.Writer)
+		zw.Reset(&compressed)
+		zw.Write(data)
+		err = zw.Close()
+		zlibWriters.Put(zw)
+		if err != nil {
+			return "", err
+		}
+		entry = binary.BigEndian.AppendUint32(entry, uint32(compressed.Len()))
+		write(entry)
+		write(compressed.Bytes())
+
+		window = append(window, windowEntry{obj: obj, content: content})
+		if len(window) > deltaWindow {
+			window = window[1:]
+		}
+	}
+
+	if err := pack.Flush(); err != nil {
+		return "", fmt.Errorf("failed to write pack: %w", err)
+	}
+	sum := checksum.Sum(nil)
+	if _, err := tmp.Write(sum); err != nil {
+		return "", fmt.Errorf("failed to write pack: %w", err)
+	}
+	stats.PackedBytes = offset + int64(len(sum))
+	if err := tmp.Sync(); err != nil {
+		return "", fmt.Errorf("failed to write pack: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("failed to write pack: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0444); err != nil {
+		return "", fmt.Errorf("failed to write pack: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), base+".pack"); err != nil {
+		return "", fmt.Errorf("failed to write pack: %w", err)
+	}
+
+	var idx bytes.Buffer
+	idx.WriteString(indexMagic)
+	binary.Write(&idx, binary.BigEndian, uint32(packVersion))
+	binary.Write(&idx, binary.BigEndian, uint32(len(names)))
+	for _, hash := range names {
+		raw, _ := hex.DecodeString(hash)
+		idx.Write(raw)
+		binary.Write(&idx, binary.BigEndian, uint64(offsets[hash]))
+	}
+	idx.Write(sum)
+
+	if err := utils.WriteFileAtomic(base+".idx", idx.Bytes(), 0444); err != nil {
+		return "", fmt.Errorf("failed to write pack index: %w", err)
+	}
+	return name, nil
+}
+
+func readPackIndex(path string) (map[string]int64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 12+20 || string(data[:4]) != indexMagic {
+		return nil, fmt.Errorf("not a pack index")
+	}
+	if version := binary.BigEndian.Uint32(data[4:8]); version != packVersion {
+		return nil, fmt.Errorf("unsupported pack index version %d", version)
+	}
+	count := int(binary.BigEndian.Uint32(data[8:12]))
+	if len(data) != 12+count*28+20 {
+		return nil, fmt.Errorf("pack index has the wrong size")
+	}
+
+	entries := make(map[string]int64, count)
+	for i := 0; i < count; i++ {
+		entry := data[12+i*28 : 12+(i+1)*28]
+		entries[hex.EncodeToString(entry[:20])] = int64(binary.BigEndian.Uint64(entry[20:]))
+	}
+	return entries, nil
+}
+
+func dedupe(hashes []string) []string {
+	seen := make(map[string]bool, len(hashes))
+	unique := hashes[:0]
+	for _, hash := range hashes {
+		if !seen[hash] {
+			seen[hash] = true
+			unique = append(unique, hash)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,278 @@
+package storage
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// storeVersions stores n edits of the same text, which pack as deltas.
+func storeVersions(t *testing.T, cs *ContentStore, n int) map[string][]byte {
+	t.Helper()
+	objects := make(map[string][]byte)
+	text := strings.Repeat("a line that stays the same in every version\n", 100)
+	for i := 0; i < n; i++ {
+		content := []byte(fmt.Sprintf("%sversion %d\n", text, i))
+		hash, err := cs.Store(content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		objects[hash] = content
+	}
+	return objects
+}
+
+func checkObjects(t *testing.T, cs *ContentStore, objects map[string][]byte) {
+	t.Helper()
+	for hash, want := range objects {
+		_, got, err := cs.GetObject(hash)
+		if err != nil {
+			t.Fatalf("%s: %v", hash, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("%s reads back as %q, want %q", hash, got, want)
+		}
+	}
+}
+
+func TestPackRoundTrip(t *testing.T) {
+	cs := newStore(t)
+	objects := storeVersions(t, cs, 30)
+	tree, err := cs.StoreObject(TreeObject, []byte("100644 a.txt\x00hash\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := cs.Pack(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Objects != 31 {
+		t.Errorf("packed %d objects, want 31", stats.Objects)
+	}
+	if stats.Deltas == 0 {
+		t.Error("no object was stored as a delta")
+	}
+	if stats.PackedBytes >= stats.LooseBytes {
+		t.Errorf("pack is %d bytes, loose objects were %d", stats.PackedBytes, stats.LooseBytes)
+	}
+	if loose, err := cs.LooseHashes(); err != nil || len(loose) != 0 {
+		t.Errorf("loose objects left after packing: %v, %v", loose, err)
+	}
+
+	// A fresh store reads the pack and index back from disk.
+	reopened := NewContentStore(cs.layout)
+	checkObjects(t, reopened, objects)
+	if kind, _, err := reopened.GetObject(tree); err != nil || kind != TreeObject {
+		t.Errorf("tree reads back as %q, %v", kind, err)
+	}
+	packed, err := reopened.PackedHashes()
+	if err != nil || len(packed) != 31 {
+		t.Errorf("index lists %d objects, %v; want 31", len(packed), err)
+	}
+	for hash := range objects {
+		if err := reopened.Verify(hash); err != nil {
+			t.Errorf("%s: %v", hash, err)
+		}
+	}
+	if problems := reopened.VerifyPacks(); len(problems) != 0 {
+		t.Errorf("VerifyPacks: %v", problems)
+	}
+}
+
+func TestPackAllAndRepack(t *testing.T) {
+	cs := newStore(t)
+	first := storeVersions(t, cs, 5)
+	if _, err := cs.Pack(false); err != nil {
+		t.Fatal(err)
+	}
+	extra, err := cs.Store([]byte("added after the first pack\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cs.Pack(true); err != nil {
+		t.Fatal(err)
+	}
+	packs, _ := filepath.Glob(filepath.Join(cs.layout.PackDir(), "pack-*.pack"))
+	if len(packs) != 1 {
+		t.Errorf("%d packs after pack --all, want 1", len(packs))
+	}
+	checkObjects(t, cs, first)
+
+	var keep []string
+	for hash := range first {
+		keep = append(keep, hash)
+	}
+	if _, err := cs.Repack(keep); err != nil {
+		t.Fatal(err)
+	}
+	checkObjects(t, NewContentStore(cs.layout), first)
+	if has, err := NewContentStore(cs.layout).Has(extra); err != nil || has {
+		t.Errorf("Has(dropped object) = %v, %v", has, err)
+	}
+}
+
+func TestVerifyPacksDetectsDamage(t *testing.T) {
+	cs := newStore(t)
+	storeVersions(t, cs, 3)
+	if _, err := cs.Pack(false); err != nil {
+		t.Fatal(err)
+	}
+	packs, _ := filepath.Glob(filepath.Join(cs.layout.PackDir(), "pack-*.pack"))
+	data, err := os.ReadFile(packs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[20] ^= 0xff
+	os.Chmod(packs[0], 0644)
+	if err := os.WriteFile(packs[0], data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if problems := cs.VerifyPacks(); len(problems) != 1 {
+		t.Errorf("VerifyPacks found %d problems, want 1", len(problems))
+	}
+}
+
+type testEntry struct {
+	hash string
+	base string
+	data []byte
+}
+
+// writeTestPack writes a pack and index by hand, so tests can build packs
+// writePack would never produce.
+func writeTestPack(t *testing.T, cs *ContentStore, entries []testEntry) {
+	t.Helper()
+	var pack bytes.Buffer
+	pack.WriteString(packMagic)
+	binary.Write(&pack, binary.BigEndian, uint32(packVersion))
+	binary.Write(&pack, binary.BigEndian, uint32(len(entries)))
+
+	offsets := make(map[string]int64)
+	for _, e := range entries {
+		offsets[e.hash] = int64(pack.Len())
+		entryType := byte(packEntryFull)
+		if e.base != "" {
+			entryType = packEntryDelta
+		}
+		pack.WriteByte(entryType)
+		pack.WriteByte(byte(len(BlobObject)))
+		pack.WriteString(BlobObject)
+		if e.base != "" {
+			raw, _ := hex.DecodeString(e.base)
+			pack.Write(raw)
+		}
+		var compressed bytes.Buffer
+		zw := zlib.NewWriter(&compressed)
+		zw.Write(e.data)
+		zw.Close()
+		binary.Write(&pack, binary.BigEndian, uint32(compressed.Len()))
+		pack.Write(compressed.Bytes())
+	}
+	sum := sha1.Sum(pack.Bytes())
+	pack.Write(sum[:])
+
+	var idx bytes.Buffer
+	idx.WriteString(indexMagic)
+	binary.Write(&idx, binary.BigEndian, uint32(packVersion))
+	binary.Write(&idx, binary.BigEndian, uint32(len(entries)))
+	for _, e := range entries {
+		raw, _ := hex.DecodeString(e.hash)
+		idx.Write(raw)
+		binary.Write(&idx, binary.BigEndian, uint64(offsets[e.hash]))
+	}
+	idx.Write(sum[:])
+
+	if err := os.MkdirAll(cs.layout.PackDir(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	base := filepath.Join(cs.layout.PackDir(), "pack-test")
+	if err := os.WriteFile(base+".pack", pack.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(base+".idx", idx.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadPackedResolvesDeltaChains(t *testing.T) {
+	cs := newStore(t)
+	versions := [][]byte{[]byte(strings.Repeat("base content line\n", 20))}
+	for i := 1; i < 5; i++ {
+		versions = append(versions, append(append([]byte(nil), versions[i-1]...), fmt.Sprintf("line %d\n", i)...))
+	}
+	entries := []testEntry{{hash: Hash(versions[0]), data: versions[0]}}
+	for i := 1; i < len(versions); i++ {
+		entries = append(entries, testEntry{
+			hash: Hash(versions[i]),
+			base: Hash(versions[i-1]),
+			data: MakeDelta(versions[i-1], versions[i]),
+		})
+	}
+	writeTestPack(t, cs, entries)
+
+	for _, version := range versions {
+		checkObjects(t, cs, map[string][]byte{Hash(version): version})
+	}
+}
+
+func TestReadPackedRejectsCorruptChains(t *testing.T) {
+	a, b := []byte("content a\n"), []byte("content b\n")
+	ha, hb := Hash(a), Hash(b)
+	missing := Hash([]byte("never stored"))
+
+	tests := []struct {
+		name    string
+		entries []testEntry
+	}{
+		{"cycle", []testEntry{
+			{hash: ha, base: hb, data: MakeDelta(b, a)},
+			{hash: hb, base: ha, data: MakeDelta(a, b)},
+		}},
+		{"missing base", []testEntry{
+			{hash: ha, base: missing, data: MakeDelta(b, a)},
+		}},
+		{"delta against the wrong base", []testEntry{
+			{hash: hb, data: []byte("a base of another size\n")},
+			{hash: ha, base: hb, data: MakeDelta(b, a)},
+		}},
+		{"garbage delta", []testEntry{
+			{hash: hb, data: b},
+			{hash: ha, base: hb, data: []byte{0xff, 0xff}},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs := newStore(t)
+			writeTestPack(t, cs, tt.entries)
+			if _, _, err := cs.GetObject(ha); err == nil {
+				t.Error("read an object through a corrupt delta chain without error")
+			}
+		})
+	}
+}
+
+func TestReadPackedRejectsTruncatedEntries(t *testing.T) {
+	cs := newStore(t)
+	content := []byte(strings.Repeat("some content\n", 50))
+	writeTestPack(t, cs, []testEntry{{hash: Hash(content), data: content}})
+
+	packPath := filepath.Join(cs.layout.PackDir(), "pack-test.pack")
+	data, err := os.ReadFile(packPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(packPath, data[:30], 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := cs.GetObject(Hash(content)); err == nil {
+		t.Error("read a truncated pack entry without error")
+	}
+}
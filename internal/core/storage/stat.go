@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/jolovicdev/nora/internal/utils"
+)
+
+// racyWindow keeps files modified this recently out of the stat cache: a
+// second write within the filesystem's timestamp granularity would leave
+// mtime and size unchanged, and the stale hash would hide the edit.
+const racyWindow = 2 * time.Second
+
+// StatEntry remembers the hash of a working file together with the stat
+// data it had when it was hashed.
+type StatEntry struct {
+	Hash    string      `json:"hash"`
+	ModTime int64       `json:"mtime"`
+	Size    int64       `json:"size"`
+	Inode   uint64      `json:"inode,omitempty"`
+	Mode    os.FileMode `json:"mode"`
+}
+
+// StatCache lets status and prepare skip reading files whose stat data is
+// unchanged since they were last hashed.
+type StatCache map[string]StatEntry
+
+func (c StatCache) Lookup(path string, info os.FileInfo) (string, bool) {
+	entry, ok := c[path]
+	if !ok {
+		return "", false
+	}
+	if entry.ModTime != info.ModTime().UnixNano() || entry.Size != info.Size() ||
+		entry.Mode != info.Mode() || entry.Inode != inode(info) {
+		return "", false
+	}
+	return entry.Hash, true
+}
+
+func (c StatCache) Record(path string, info os.FileInfo, hash string) {
+	if time.Since(info.ModTime()) < racyWindow {
+		delete(c, path)
+		return
+	}
+	c[path] = StatEntry{
+		Hash:    hash,
+		ModTime: info.ModTime().UnixNano(),
+		Size:    info.Size(),
+		Inode:   inode(info),
+		Mode:    info.Mode(),
+	}
+}
+
+func (idx *Index) GetStatCache() (StatCache, error) {
+	cache := make(StatCache)
+	data, err := os.ReadFile(idx.layout.StatCache())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cache, nil
+		}
+		return nil, err
+	}
+	err = json.Unmarshal(data, &cache)
+	return cache, err
+}
+
+func (idx *Index) SaveStatCache(cache StatCache) error {
+	data, err := json.Marshal(cache)
+	if err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(idx.layout.StatCache(), data, 0644)
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package storage
+
+import "os"
+
+// Inode numbers are not exposed through os.FileInfo here, so the stat
+// cache relies on mtime, size and mode alone.
+func inode(info os.FileInfo) uint64 {
+	return 0
+}
@@ -5,153 +5,341 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 
+	"github.com/jolovicdev/nora/internal/core/layout"
 	"github.com/jolovicdev/nora/internal/types"
+	"github.com/jolovicdev/nora/internal/utils"
 )
 
 type Manager struct {
-    rootPath string
-    config   *types.Config
+	layout *layout.Layout
+	config *types.Config
 }
 
-func NewManager(rootPath string) *Manager {
-    return &Manager{
-        rootPath: rootPath,
-        config: &types.Config{
-            CurrentTimeline: "main",
-            Timelines:      make(map[string]string),
-        },
-    }
+func NewManager(layout *layout.Layout) *Manager {
+	return &Manager{
+		layout: layout,
+		config: &types.Config{
+			CurrentTimeline: "main",
+			Timelines:       make(map[string]string),
+		},
+	}
 }
 
 func (m *Manager) loadConfig() error {
-    configPath := filepath.Join(m.rootPath, ".nora", "config", "config.json")
-    
+	configPath := m.layout.State()
+
+	configDir := filepath.Dir(configPath)
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+
+			m.config = &types.Config{
+				CurrentTimeline: "main",
+				Timelines:       map[string]string{"main": "main"},
+			}
+			return m.saveConfig()
+		}
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &m.config); err != nil {
+		return fmt.Errorf("failed to parse config: %w", err)
+	}
+
+	if m.config.Timelines == nil {
+		m.config.Timelines = make(map[string]string)
+	}
+
+	return nil
+}
 
-    configDir := filepath.Dir(configPath)
-    if err := os.MkdirAll(configDir, 0755); err != nil {
-        return fmt.Errorf("failed to create config directory: %w", err)
-    }
-    
-    data, err := os.ReadFile(configPath)
-    if err != nil {
-        if os.IsNotExist(err) {
+func (m *Manager) saveConfig() error {
+	configPath := m.layout.State()
 
-            m.config = &types.Config{
-                CurrentTimeline: "main",
-                Timelines: map[string]string{"main": "main"},
-            }
-            return m.saveConfig()
-        }
-        return fmt.Errorf("failed to read config: %w", err)
-    }
+	data, err := json.MarshalIndent(m.config, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
 
-    if err := json.Unmarshal(data, &m.config); err != nil {
-        return fmt.Errorf("failed to parse config: %w", err)
-    }
+	if err := utils.WriteFileAtomic(configPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
 
+	return nil
+}
 
-    if m.config.Timelines == nil {
-        m.config.Timelines = make(map[string]string)
-    }
+func (m *Manager) Create(name string) error {
+	timeline := types.Timeline{
+		Name:      name,
+		Current:   "",
+		Snapshots: []string{},
+	}
 
-    return nil
-}
+	timelinePath := m.layout.Timeline(name)
 
-func (m *Manager) saveConfig() error {
-    configPath := filepath.Join(m.rootPath, ".nora", "config", "config.json")
-    
-    data, err := json.MarshalIndent(m.config, "", "  ")
-    if err != nil {
-        return fmt.Errorf("failed to marshal config: %w", err)
-    }
+	timelineDir := filepath.Dir(timelinePath)
+	if err := os.MkdirAll(timelineDir, 0755); err != nil {
+		return fmt.Errorf("failed to create timelines directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(timeline, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal timeline: %w", err)
+	}
 
-    if err := os.WriteFile(configPath, data, 0644); err != nil {
-        return fmt.Errorf("failed to write config: %w", err)
-    }
+	if err := utils.WriteFileAtomic(timelinePath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write timeline: %w", err)
+	}
 
-    return nil
+	if err := m.loadConfig(); err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	m.config.Timelines[name] = name
+	m.config.CurrentTimeline = name
+
+	return m.saveConfig()
 }
 
-func (m *Manager) Create(name string) error {
-    timeline := types.Timeline{
-        Name:      name,
-        Current:   "",
-        Snapshots: []string{},
-    }
+func (m *Manager) GetCurrent() (*types.Timeline, error) {
+	if err := m.loadConfig(); err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	if m.config.CurrentTimeline == "" {
+		return nil, fmt.Errorf("no current timeline set")
+	}
+
+	timelinePath := m.layout.Timeline(m.config.CurrentTimeline)
+	data, err := os.ReadFile(timelinePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+
+			timeline := &types.Timeline{
+				Name:      m.config.CurrentTimeline,
+				Current:   "",
+				Snapshots: []string{},
+			}
+			return timeline, m.Update(timeline)
+		}
+		return nil, fmt.Errorf("failed to read timeline %s: %w", m.config.CurrentTimeline, err)
+	}
+
+	var timeline types.Timeline
+	if err := json.Unmarshal(data, &timeline); err != nil {
+		return nil, fmt.Errorf("failed to parse timeline: %w", err)
+	}
+
+	return &timeline, nil
+}
+
+func (m *Manager) Update(timeline *types.Timeline) error {
+	timelinePath := m.layout.Timeline(timeline.Name)
 
+	data, err := json.MarshalIndent(timeline, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal timeline: %w", err)
+	}
 
-    timelinePath := filepath.Join(m.rootPath, ".nora", "timelines", name+".json")
-    
+	if err := utils.WriteFileAtomic(timelinePath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write timeline: %w", err)
+	}
 
-    timelineDir := filepath.Dir(timelinePath)
-    if err := os.MkdirAll(timelineDir, 0755); err != nil {
-        return fmt.Errorf("failed to create timelines directory: %w", err)
-    }
+	return nil
+}
 
-    data, err := json.MarshalIndent(timeline, "", "  ")
-    if err != nil {
-        return fmt.Errorf("failed to marshal timeline: %w", err)
-    }
+func (m *Manager) timelinePath(name string) string {
+	return m.layout.Timeline(name)
+}
 
-    if err := os.WriteFile(timelinePath, data, 0644); err != nil {
-        return fmt.Errorf("failed to write timeline: %w", err)
-    }
+func validateName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, "/\\") || strings.HasPrefix(name, "-") {
+		return fmt.Errorf("invalid timeline name: %q", name)
+	}
+	return nil
+}
 
+func (m *Manager) Exists(name string) (bool, error) {
+	if err := m.loadConfig(); err != nil {
+		return false, fmt.Errorf("failed to load config: %w", err)
+	}
+	_, ok := m.config.Timelines[name]
+	return ok, nil
+}
 
-    if err := m.loadConfig(); err != nil {
-        return fmt.Errorf("failed to load config: %w", err)
-    }
+func (m *Manager) Get(name string) (*types.Timeline, error) {
+	exists, err := m.Exists(name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("timeline not found: %s", name)
+	}
+
+	data, err := os.ReadFile(m.timelinePath(name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &types.Timeline{Name: name, Snapshots: []string{}}, nil
+		}
+		return nil, fmt.Errorf("failed to read timeline %s: %w", name, err)
+	}
+
+	var timeline types.Timeline
+	if err := json.Unmarshal(data, &timeline); err != nil {
+		return nil, fmt.Errorf("failed to parse timeline %s: %w", name, err)
+	}
+	return &timeline, nil
+}
 
-    m.config.Timelines[name] = name
-    m.config.CurrentTimeline = name
+func (m *Manager) Config() (*types.Config, error) {
+	if err := m.loadConfig(); err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	return m.config, nil
+}
 
-    return m.saveConfig()
+func (m *Manager) SetFormatVersion(version int) error {
+	return m.UpdateConfig(func(config *types.Config) {
+		config.FormatVersion = version
+	})
 }
 
-func (m *Manager) GetCurrent() (*types.Timeline, error) {
-    if err := m.loadConfig(); err != nil {
-        return nil, fmt.Errorf("failed to load config: %w", err)
-    }
+func (m *Manager) UpdateConfig(update func(*types.Config)) error {
+	if err := m.loadConfig(); err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	update(m.config)
+	return m.saveConfig()
+}
 
-    if m.config.CurrentTimeline == "" {
-        return nil, fmt.Errorf("no current timeline set")
-    }
+func (m *Manager) CurrentName() (string, error) {
+	if err := m.loadConfig(); err != nil {
+		return "", fmt.Errorf("failed to load config: %w", err)
+	}
+	return m.config.CurrentTimeline, nil
+}
 
-    timelinePath := filepath.Join(m.rootPath, ".nora", "timelines", m.config.CurrentTimeline+".json")
-    data, err := os.ReadFile(timelinePath)
-    if err != nil {
-        if os.IsNotExist(err) {
+func (m *Manager) List() ([]*types.Timeline, error) {
+	if err := m.loadConfig(); err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	names := make([]string, 0, len(m.config.Timelines))
+	for name := range m.config.Timelines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	timelines := make([]*types.Timeline, 0, len(names))
+	for _, name := range names {
+		timeline, err := m.Get(name)
+		if err != nil {
+			return nil, err
+		}
+		timelines = append(timelines, timeline)
+	}
+	return timelines, nil
+}
 
-            timeline := &types.Timeline{
-                Name:      m.config.CurrentTimeline,
-                Current:   "",
-                Snapshots: []string{},
-            }
-            return timeline, m.Update(timeline)
-        }
-        return nil, fmt.Errorf("failed to read timeline %s: %w", m.config.CurrentTimeline, err)
-    }
+func (m *Manager) Fork(name string, from *types.Timeline) error {
+	if err := validateName(name); err != nil {
+		return err
+	}
+	exists, err := m.Exists(name)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("timeline already exists: %s", name)
+	}
+
+	timeline := &types.Timeline{
+		Name:      name,
+		Current:   from.Current,
+		Snapshots: append([]string{}, from.Snapshots...),
+	}
+	if err := os.MkdirAll(filepath.Dir(m.timelinePath(name)), 0755); err != nil {
+		return fmt.Errorf("failed to create timelines directory: %w", err)
+	}
+	if err := m.Update(timeline); err != nil {
+		return err
+	}
+
+	m.config.Timelines[name] = name
+	return m.saveConfig()
+}
 
-    var timeline types.Timeline
-    if err := json.Unmarshal(data, &timeline); err != nil {
-        return nil, fmt.Errorf("failed to parse timeline: %w", err)
-    }
+func (m *Manager) Switch(name string) error {
+	exists, err := m.Exists(name)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("timeline not found: %s", name)
+	}
+
+	m.config.CurrentTimeline = name
+	return m.saveConfig()
+}
 
-    return &timeline, nil
+func (m *Manager) Delete(name string) error {
+	exists, err := m.Exists(name)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("timeline not found: %s", name)
+	}
+	if name == m.config.CurrentTimeline {
+		return fmt.Errorf("cannot delete the current timeline: %s", name)
+	}
+
+	delete(m.config.Timelines, name)
+	if err := m.saveConfig(); err != nil {
+		return err
+	}
+
+	if err := os.Remove(m.timelinePath(name)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove timeline %s: %w", name, err)
+	}
+	return nil
 }
 
-func (m *Manager) Update(timeline *types.Timeline) error {
-    timelinePath := filepath.Join(m.rootPath, ".nora", "timelines", timeline.Name+".json")
-    
-    data, err := json.MarshalIndent(timeline, "", "  ")
-    if err != nil {
-        return fmt.Errorf("failed to marshal timeline: %w", err)
-    }
-
-    if err := os.WriteFile(timelinePath, data, 0644); err != nil {
-        return fmt.Errorf("failed to write timeline: %w", err)
-    }
-
-    return nil
-}
\ No newline at end of file
+func (m *Manager) Rename(oldName, newName string) error {
+	if err := validateName(newName); err != nil {
+		return err
+	}
+	timeline, err := m.Get(oldName)
+	if err != nil {
+		return err
+	}
+	if _, exists := m.config.Timelines[newName]; exists {
+		return fmt.Errorf("timeline already exists: %s", newName)
+	}
+
+	timeline.Name = newName
+	if err := m.Update(timeline); err != nil {
+		return err
+	}
+
+	delete(m.config.Timelines, oldName)
+	m.config.Timelines[newName] = newName
+	if m.config.CurrentTimeline == oldName {
+		m.config.CurrentTimeline = newName
+	}
+	if err := m.saveConfig(); err != nil {
+		return err
+	}
+
+	if err := os.Remove(m.timelinePath(oldName)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove old timeline file %s: %w", oldName, err)
+	}
+	return nil
+}
@@ -3,16 +3,43 @@ package types
 import "os"
 
 type Snapshot struct {
-	ID        string            `json:"id"`
-	Timestamp int64            `json:"timestamp"`
-	Message   string            `json:"message"`
-	Files     map[string]string `json:"files"`
-	Parent    string            `json:"parent"`
+	ID        string                 `json:"id"`
+	Timestamp int64                  `json:"timestamp"`
+	Message   string                 `json:"message"`
+	Tree      string                 `json:"tree,omitempty"`
+	Files     map[string]string      `json:"files,omitempty"`
+	Parent    string                 `json:"parent"`
+	Modes     map[string]os.FileMode `json:"modes,omitempty"`
+	Author    *Signature             `json:"author,omitempty"`
+	Committer *Signature             `json:"committer,omitempty"`
+}
+
+// Signature records who made a change and when. Offset is the UTC offset
+// in minutes the time was recorded in, so it can be shown in that zone.
+type Signature struct {
+	Name   string `json:"name"`
+	Email  string `json:"email"`
+	When   int64  `json:"when"`
+	Offset int    `json:"offset"`
+}
+
+type TreeEntry struct {
+	Path string      `json:"path"`
+	Hash string      `json:"hash"`
+	Mode os.FileMode `json:"mode,omitempty"`
 }
 
 type FileChange struct {
-    Path string
-    State string
+	Path   string
+	State  string
+	From   string
+	Binary bool
+}
+
+type Rename struct {
+	From       string
+	To         string
+	Similarity float64
 }
 
 type Timeline struct {
@@ -21,15 +48,16 @@ type Timeline struct {
 	Snapshots []string `json:"snapshots"`
 }
 type FileStatus struct {
-    Path     string
-    State    string
-    Mode     os.FileMode
-    IsSymlink bool
+	Path      string
+	State     string
+	Mode      os.FileMode
+	IsSymlink bool
 }
 
 type Config struct {
 	CurrentTimeline string            `json:"current_timeline"`
-	Timelines      map[string]string `json:"timelines"`
+	Timelines       map[string]string `json:"timelines"`
+	FormatVersion   int               `json:"format_version,omitempty"`
 }
 
 type Status struct {
@@ -1,9 +1,6 @@
 package utils
 
 import (
-	"crypto/rand"
-	"crypto/sha1"
-	"encoding/hex"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -11,18 +8,19 @@ import (
 	"time"
 )
 
-const(
-	Reset = "\033[0m" 
-	Red = "\033[31m"
-	Green = "\033[32m" 
-	Yellow = "\033[33m" 
-	Blue = "\033[34m" 
-	Magenta = "\033[35m" 
-	Cyan = "\033[36m" 
-	Gray = "\033[37m" 
-	White = "\033[97m"
-	Bold      = "\033[1m"
+const (
+	Reset   = "\033[0m"
+	Red     = "\033[31m"
+	Green   = "\033[32m"
+	Yellow  = "\033[33m"
+	Blue    = "\033[34m"
+	Magenta = "\033[35m"
+	Cyan    = "\033[36m"
+	Gray    = "\033[37m"
+	White   = "\033[97m"
+	Bold    = "\033[1m"
 )
+
 func CreateDirIfNotExists(path string) error {
 	if _, err := os.Stat(path); os.IsNotExist(err) {
 		return os.MkdirAll(path, 0755)
@@ -30,61 +28,135 @@ func CreateDirIfNotExists(path string) error {
 	return nil
 }
 
+// WriteFileAtomic writes data to a temporary file next to path and renames
+// it into place, so readers and crashes only ever see the old or the new
+// content, never a truncated file.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
 
-func GenerateID() string {
-    timestamp := time.Now().UnixNano()
-    randomBytes := make([]byte, 4)
-    rand.Read(randomBytes)
-    data := append([]byte(fmt.Sprintf("%d", timestamp)), randomBytes...)
-    hash := sha1.Sum(data)
-    return hex.EncodeToString(hash[:])[:12]
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
 }
 
 func PrintInitMessage(directory string) {
-    width := 60
-    dirName := filepath.Base(directory)
+	width := 60
+	dirName := filepath.Base(directory)
 
-    topBottom := fmt.Sprintf("%s╔%s╗%s", Cyan, strings.Repeat("═", width-2), Reset)
-    empty := fmt.Sprintf("%s║%s║%s", Cyan, strings.Repeat(" ", width-2), Reset)
-    
-    mainMsg := "Nora Version Control Initialized!"
-    initMsg := fmt.Sprintf("Initialized in: %s", dirName)
-    timeMsg := time.Now().Format("2006-01-02 15:04:05")
+	topBottom := fmt.Sprintf("%s╔%s╗%s", Cyan, strings.Repeat("═", width-2), Reset)
+	empty := fmt.Sprintf("%s║%s║%s", Cyan, strings.Repeat(" ", width-2), Reset)
 
-    mainMsgPadding := (width - 2 - len(mainMsg)) / 2
-    initMsgPadding := (width - 2 - len(initMsg)) / 2
-    timeMsgPadding := (width - 2 - len(timeMsg)) / 2
+	mainMsg := "Nora Version Control Initialized!"
+	initMsg := fmt.Sprintf("Initialized in: %s", dirName)
+	timeMsg := time.Now().Format("2006-01-02 15:04:05")
 
-    fmt.Println()
-    fmt.Println(topBottom)
-    fmt.Println(empty)
-    fmt.Printf("%s║%s%s%s%s%s║%s\n", 
-        Cyan, 
-        strings.Repeat(" ", mainMsgPadding),
-        Magenta + Bold + mainMsg + Reset,
-        strings.Repeat(" ", width-2-mainMsgPadding-len(mainMsg)),
-        Reset,
-        Cyan,
-        Reset)
-    fmt.Println(empty)
-    fmt.Printf("%s║%s%s%s%s%s║%s\n",
-        Cyan,
-        strings.Repeat(" ", initMsgPadding),
-        Green + initMsg + Reset,
-        strings.Repeat(" ", width-2-initMsgPadding-len(initMsg)),
-        Reset,
-        Cyan,
-        Reset)
-    fmt.Println(empty)
-    fmt.Printf("%s║%s%s%s%s%s║%s\n",
-        Cyan,
-        strings.Repeat(" ", timeMsgPadding),
-        Yellow + timeMsg + Reset,
-        strings.Repeat(" ", width-2-timeMsgPadding-len(timeMsg)),
-        Reset,
-        Cyan,
-        Reset)
-    fmt.Println(empty)
-    fmt.Printf("%s╚%s╝%s\n", Cyan, strings.Repeat("═", width-2), Reset)
-    fmt.Println()
-}
\ No newline at end of file
+	mainMsgPadding := (width - 2 - len(mainMsg)) / 2
+	initMsgPadding := (width - 2 - len(initMsg)) / 2
+	timeMsgPadding := (width - 2 - len(timeMsg)) / 2
+
+	fmt.Println()
+	fmt.Println(topBottom)
+	fmt.Println(empty)
+	fmt.Printf("%s║%s%s%s%s%s║%s\n",
+		Cyan,
+		strings.Repeat(" ", mainMsgPadding),
+		Magenta+Bold+mainMsg+Reset,
+		strings.Repeat(" ", width-2-mainMsgPadding-len(mainMsg)),
+		Reset,
+		Cyan,
+		Reset)
+	fmt.Println(empty)
+	fmt.Printf("%s║%s%s%s%s%s║%s\n",
+		Cyan,
+		strings.Repeat(" ", initMsgPadding),
+		Green+initMsg+Reset,
+		strings.Repeat(" ", width-2-initMsgPadding-len(initMsg)),
+		Reset,
+		Cyan,
+		Reset)
+	fmt.Println(empty)
+	fmt.Printf("%s║%s%s%s%s%s║%s\n",
+		Cyan,
+		strings.Repeat(" ", timeMsgPadding),
+		Yellow+timeMsg+Reset,
+		strings.Repeat(" ", width-2-timeMsgPadding-len(timeMsg)),
+		Reset,
+		Cyan,
+		Reset)
+	fmt.Println(empty)
+	fmt.Printf("%s╚%s╝%s\n", Cyan, strings.Repeat("═", width-2), Reset)
+	fmt.Println()
+}
+func ParseDate(value string, now time.Time) (time.Time, error) {
+	layouts := []string{
+		time.RFC3339,
+		"Mon Jan 2 15:04:05 2006 -0700",
+		"2006-01-02 15:04:05 -0700",
+		"2006-01-02 15:04:05",
+		"2006-01-02 15:04",
+		"2006-01-02",
+	}
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	ago := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(value), "ago"))
+	units := map[string]time.Duration{
+		"minute": time.Minute,
+		"hour":   time.Hour,
+		"day":    24 * time.Hour,
+		"week":   7 * 24 * time.Hour,
+	}
+	var n int
+	var unit string
+	if _, err := fmt.Sscanf(ago, "%d %s", &n, &unit); err == nil {
+		if d, ok := units[strings.TrimSuffix(unit, "s")]; ok {
+			return now.Add(-time.Duration(n) * d), nil
+		}
+	}
+	if d, err := time.ParseDuration(ago); err == nil {
+		return now.Add(-d), nil
+	}
+
+	return time.Time{}, fmt.Errorf("invalid date: %q", value)
+}
+
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+func FormatSize(size int64) string {
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+	value := float64(size)
+	unit := 0
+	for value >= 1024 && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+	if unit == 0 || value >= 10 {
+		return fmt.Sprintf("%.0f %s", value, units[unit])
+	}
+	return fmt.Sprintf("%.1f %s", value, units[unit])
+}